@@ -30,6 +30,7 @@ local FROG = {
     { word = "&&",        type = "operator", color = api.FGColors.Brights.Magenta, version = "all" },
     { word = "Break",     type = "control",  color = api.FGColors.Brights.Red,     version = "all" },
     { word = "Continue",  type = "control",  color = api.FGColors.Brights.Red,     version = "all" },
+    { word = "Assert",    type = "control",  color = api.FGColors.Brights.Red,     version = "all" },
     { word = "FRG_Fn",    type = "function", color = api.FGColors.Brights.Blue,    version = "all" },
     { word = "FRG_Print", type = "function", color = api.FGColors.Brights.Blue,    version = "all" },
     { word = "FRG_Input", type = "function", color = api.FGColors.Brights.Blue,    version = "all" },
@@ -40,6 +41,12 @@ local FROG = {
     { word = "Else",      type = "control",  color = api.FGColors.Brights.Red,     version = "all" },
     { word = "If",        type = "control",  color = api.FGColors.Brights.Red,     version = "all" },
     { word = "FRG_Use",   type = "import",   color = api.FGColors.Brights.Green,   version = "all" },
+    { word = "FRG_Export", type = "import",  color = api.FGColors.Brights.Green,   version = "all" },
+    { word = "As",        type = "import",   color = api.FGColors.Brights.Green,   version = "all" },
+    { word = "From",      type = "import",   color = api.FGColors.Brights.Green,   version = "all" },
+    { word = "FRG_Static", type = "function", color = api.FGColors.Brights.Blue,   version = "all" },
+    { word = "FRG_Const", type = "function", color = api.FGColors.Brights.Blue,    version = "all" },
+    { word = "FRG_Enum",  type = "function", color = api.FGColors.Brights.Blue,    version = "all" },
     { word = "!",         type = "operator", color = api.FGColors.Brights.Magenta, version = "all" },
     { word = "||",        type = "operator", color = api.FGColors.Brights.Magenta, version = "all" },
     { word = "Repeat",    type = "control",  color = api.FGColors.Brights.Red,     version = "all" },
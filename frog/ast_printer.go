@@ -48,6 +48,12 @@ func PrintAST(node Node, prefix string, isLast bool) {
 		if n.Alternative != nil {
 			PrintAST(n.Alternative, childPrefix, true)
 		}
+	case *AssertStatement:
+		fmt.Println("AssertStatement:")
+		PrintAST(n.Condition, childPrefix, n.Message == nil)
+		if n.Message != nil {
+			PrintAST(n.Message, childPrefix, true)
+		}
 	case *RepeatStatement:
 		fmt.Println("RepeatStatement:")
 		PrintAST(n.Condition, childPrefix, false)
@@ -59,9 +65,41 @@ func PrintAST(node Node, prefix string, isLast bool) {
 		for i, stmt := range n.Statements {
 			PrintAST(stmt, childPrefix, i == len(n.Statements)-1)
 		}
+	case *UseStatement:
+		if n.Alias != nil {
+			fmt.Printf("UseStatement: %q As %s\n", n.Filename.Value, n.Alias.Value)
+		} else {
+			fmt.Printf("UseStatement: %q\n", n.Filename.Value)
+			for i, name := range n.Names {
+				PrintAST(name, childPrefix, i == len(n.Names)-1 && n.Module == nil)
+			}
+		}
+		if n.Module != nil {
+			PrintAST(n.Module, childPrefix, true)
+		}
+	case *ExportStatement:
+		fmt.Println("ExportStatement:")
+		PrintAST(n.Statement, childPrefix, true)
+	case *ConstStatement:
+		fmt.Println("ConstStatement:")
+		for i, name := range n.Names {
+			PrintAST(name, childPrefix, false)
+			PrintAST(n.Values[i], childPrefix, i == len(n.Names)-1)
+		}
+	case *EnumStatement:
+		fmt.Printf("EnumStatement: %s\n", n.Name.Value)
+		for i, member := range n.Members {
+			PrintAST(member, childPrefix, i == len(n.Members)-1)
+		}
+	case *StaticStatement:
+		fmt.Println("StaticStatement:")
+		PrintAST(n.Declaration, childPrefix, true)
 	case *FunctionDeclarationStatement:
 		fmt.Printf("FunctionDeclarationStatement: %s\n", n.Name.Value)
 		PrintAST(n.Body, childPrefix, true)
+	case *FunctionLiteral:
+		fmt.Println("FunctionLiteral:")
+		PrintAST(n.Function.Body, childPrefix, true)
 	case *ExpressionStatement:
 		fmt.Println("ExpressionStatement:")
 		PrintAST(n.Expression, childPrefix, true)
@@ -89,12 +127,21 @@ func PrintAST(node Node, prefix string, isLast bool) {
 		fmt.Println("IndexExpression:")
 		PrintAST(n.Left, childPrefix, false)
 		PrintAST(n.Index, childPrefix, true)
+	case *MemberExpression:
+		fmt.Printf("MemberExpression: %s\n", n.Member.Value)
+		PrintAST(n.Object, childPrefix, true)
 	case *CallExpression:
 		fmt.Println("CallExpression:")
 		PrintAST(n.Function, childPrefix, false)
 		for i, arg := range n.Arguments {
 			PrintAST(arg, childPrefix, i == len(n.Arguments)-1)
 		}
+	case *SpreadArgument:
+		fmt.Println("SpreadArgument:")
+		PrintAST(n.Value, childPrefix, true)
+	case *NamedArgument:
+		fmt.Printf("NamedArgument: %s\n", n.Name.Value)
+		PrintAST(n.Value, childPrefix, true)
 	case *Boolean:
 		fmt.Printf("Boolean: %t\n", n.Value)
 	default:
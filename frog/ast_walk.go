@@ -0,0 +1,207 @@
+// Copyright (C) by abdenour souane
+// you have a right to modify it upgrade it or do whatever you want
+// but u have to keep my name on it
+
+package frog
+
+import (
+	"reflect"
+)
+
+// inspect walks the AST in depth-first order like go/ast.Inspect
+// fn is called for every node, if it returns false the children of that node are skipped
+func inspect(node Node, fn func(Node) bool) {
+	if isNilNode(node) || !fn(node) {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		for _, s := range n.Statements {
+			inspect(s, fn)
+		}
+	case *DeclarationStatement:
+		for i, ident := range n.Identifiers {
+			inspect(ident, fn)
+			inspect(n.value(i), fn)
+		}
+	case *FunctionDeclarationStatement:
+		inspect(n.Name, fn)
+		for _, param := range n.Parameters {
+			inspect(param.Name, fn)
+			inspect(param.Default, fn)
+		}
+		inspect(n.Body, fn)
+	case *AssignmentStatement:
+		inspect(n.Left, fn)
+		inspect(n.Value, fn)
+	case *PrintStatement:
+		for _, e := range n.Expressions {
+			inspect(e, fn)
+		}
+	case *InputStatement:
+		for _, e := range n.Expressions {
+			inspect(e, fn)
+		}
+	case *IfStatement:
+		inspect(n.Condition, fn)
+		inspect(n.Consequence, fn)
+		inspect(n.Alternative, fn)
+	case *AssertStatement:
+		inspect(n.Condition, fn)
+		inspect(n.Message, fn)
+	case *RepeatStatement:
+		for _, s := range n.Body {
+			inspect(s, fn)
+		}
+		inspect(n.Condition, fn)
+	case *BlockStatement:
+		for _, s := range n.Statements {
+			inspect(s, fn)
+		}
+	case *UseStatement:
+		inspect(n.Alias, fn)
+		for _, name := range n.Names {
+			inspect(name, fn)
+		}
+		inspect(n.Module, fn)
+	case *ExportStatement:
+		inspect(n.Statement, fn)
+	case *StaticStatement:
+		inspect(n.Declaration, fn)
+	case *ConstStatement:
+		for i, name := range n.Names {
+			inspect(name, fn)
+			inspect(n.Values[i], fn)
+		}
+	case *EnumStatement:
+		inspect(n.Name, fn)
+		for _, member := range n.Members {
+			inspect(member, fn)
+		}
+	case *ExpressionStatement:
+		inspect(n.Expression, fn)
+	case *PrefixExpression:
+		inspect(n.Right, fn)
+	case *InfixExpression:
+		inspect(n.Left, fn)
+		inspect(n.Right, fn)
+	case *ArrayLiteral:
+		for _, e := range n.Elements {
+			inspect(e, fn)
+		}
+	case *ArraySizeLiteral:
+		inspect(n.Size, fn)
+	case *IndexExpression:
+		inspect(n.Left, fn)
+		inspect(n.Index, fn)
+	case *MemberExpression:
+		inspect(n.Object, fn)
+		inspect(n.Member, fn)
+	case *CallExpression:
+		inspect(n.Function, fn)
+		for _, arg := range n.Arguments {
+			inspect(arg, fn)
+		}
+	case *FunctionLiteral:
+		inspect(n.Function, fn)
+	case *NamedArgument:
+		// the name belongs to the parameters of the called function, not to the scope
+		inspect(n.Value, fn)
+	case *SpreadArgument:
+		inspect(n.Value, fn)
+	}
+}
+
+// isNilNode reports nil interfaces and typed nil pointers
+// the parser returns typed nils (e.g. (*IfStatement)(nil)) when a statement fails
+func isNilNode(node Node) bool {
+	if node == nil {
+		return true
+	}
+	v := reflect.ValueOf(node)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+// startToken returns the first token of a node in the source
+// most nodes keep their leading token, but infix/index/call expressions
+// keep the operator so we have to go down to the left operand
+func startToken(node Node) Token {
+	if isNilNode(node) {
+		return Token{}
+	}
+	switch n := node.(type) {
+	case *Program:
+		if len(n.Statements) > 0 {
+			return startToken(n.Statements[0])
+		}
+	case *DeclarationStatement:
+		return n.Token
+	case *FunctionDeclarationStatement:
+		return n.Token
+	case *AssignmentStatement:
+		return startToken(n.Left)
+	case *PrintStatement:
+		return n.Token
+	case *InputStatement:
+		return n.Token
+	case *IfStatement:
+		return n.Token
+	case *AssertStatement:
+		return n.Token
+	case *RepeatStatement:
+		return n.Token
+	case *BlockStatement:
+		return n.Token
+	case *BreakStatement:
+		return n.Token
+	case *ContinueStatement:
+		return n.Token
+	case *UseStatement:
+		return n.Token
+	case *ExportStatement:
+		return n.Token
+	case *StaticStatement:
+		return n.Token
+	case *ConstStatement:
+		return n.Token
+	case *EnumStatement:
+		return n.Token
+	case *ExpressionStatement:
+		if n.Token.Line != 0 {
+			return n.Token
+		}
+		return startToken(n.Expression)
+	case *Identifier:
+		return n.Token
+	case *IntegerLiteral:
+		return n.Token
+	case *RealLiteral:
+		return n.Token
+	case *StringLiteral:
+		return n.Token
+	case *Boolean:
+		return n.Token
+	case *PrefixExpression:
+		return n.Token
+	case *InfixExpression:
+		return startToken(n.Left)
+	case *ArrayLiteral:
+		return n.Token
+	case *ArraySizeLiteral:
+		return n.Token
+	case *IndexExpression:
+		return startToken(n.Left)
+	case *MemberExpression:
+		return startToken(n.Object)
+	case *CallExpression:
+		return startToken(n.Function)
+	case *FunctionLiteral:
+		return n.Token
+	case *NamedArgument:
+		return n.Name.Token
+	case *SpreadArgument:
+		return startToken(n.Value)
+	}
+	return Token{}
+}
@@ -0,0 +1,205 @@
+// Copyright (C) by abdenour souane
+// you have a right to modify it upgrade it or do whatever you want
+// but u have to keep my name on it
+
+package frog
+
+import (
+	"sort"
+)
+
+// builtins are found after the variables, so a program can still have its own map
+var builtins map[string]*Builtin
+
+func init() {
+	// filled here, the builtins call applyFunction which goes back to Eval
+	builtins = map[string]*Builtin{
+		"map":    {Name: "map", Fn: builtinMap},
+		"filter": {Name: "filter", Fn: builtinFilter},
+		"reduce": {Name: "reduce", Fn: builtinReduce},
+		"sort":   {Name: "sort", Fn: builtinSort},
+	}
+}
+
+// map(arr, f) gives a new array with f applied to every element
+func builtinMap(call *CallExpression, env *Environment, args []Object) Object {
+	arr, fn, err := arrayAndFunction(call, "map", args, 2)
+	if err != nil {
+		return err
+	}
+	out := make([]Object, 0, len(arr.Elements))
+	for _, element := range arr.Elements {
+		val := callValue(fn, []Object{element}, call, env)
+		if isError(val) {
+			return val
+		}
+		out = append(out, val)
+	}
+	if err := env.runtime.allocate(call.Token, int64(len(out))); err != nil {
+		return err
+	}
+	return &Array{Elements: out}
+}
+
+// filter(arr, f) keeps the elements for which f is true
+func builtinFilter(call *CallExpression, env *Environment, args []Object) Object {
+	arr, fn, err := arrayAndFunction(call, "filter", args, 2)
+	if err != nil {
+		return err
+	}
+	out := []Object{}
+	for _, element := range arr.Elements {
+		keep := callValue(fn, []Object{element}, call, env)
+		if isError(keep) {
+			return keep
+		}
+		if isTruthy(keep) {
+			out = append(out, element)
+		}
+	}
+	if err := env.runtime.allocate(call.Token, int64(len(out))); err != nil {
+		return err
+	}
+	return &Array{Elements: out}
+}
+
+// reduce(arr, f, init) folds the array from the left: f(f(init, a[0]), a[1])...
+func builtinReduce(call *CallExpression, env *Environment, args []Object) Object {
+	arr, fn, err := arrayAndFunction(call, "reduce", args, 3)
+	if err != nil {
+		return err
+	}
+	acc := args[2]
+	for _, element := range arr.Elements {
+		acc = callValue(fn, []Object{acc, element}, call, env)
+		if isError(acc) {
+			return acc
+		}
+	}
+	return acc
+}
+
+// sort(arr) or sort(arr, less) gives a new sorted array, equal elements keep
+// their order. without less the numbers and the strings are compared
+func builtinSort(call *CallExpression, env *Environment, args []Object) Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError(call.Token, "sort expects 1 or 2 arguments, got %d", len(args))
+	}
+	arr, ok := args[0].(*Array)
+	if !ok {
+		return newError(call.Token, "sort expects an array, got %s", typeName(args[0]))
+	}
+	if err := env.runtime.allocate(call.Token, int64(len(arr.Elements))); err != nil {
+		return err
+	}
+	out := append([]Object{}, arr.Elements...)
+	var failed Object
+	var less func(a, b Object) bool
+	if len(args) == 2 {
+		fn := args[1]
+		if !isCallable(fn) {
+			return newError(call.Token, "sort expects a FRG_Fn as the comparator, got %s", typeName(fn))
+		}
+		less = func(a, b Object) bool {
+			val := callValue(fn, []Object{a, b}, call, env)
+			if isError(val) {
+				failed = val
+				return false
+			}
+			return isTruthy(val)
+		}
+	} else {
+		less = func(a, b Object) bool {
+			result, err := compareNatural(a, b)
+			if err != "" {
+				failed = newError(call.Token, "sort cannot compare %s", err)
+				return false
+			}
+			return result
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if failed != nil {
+			return false
+		}
+		return less(out[i], out[j])
+	})
+	if failed != nil {
+		return failed
+	}
+	return &Array{Elements: out}
+}
+
+// compareNatural tells if a < b for the numbers and the strings
+func compareNatural(a, b Object) (bool, string) {
+	if x, ok := a.(*EnumValue); ok {
+		if y, ok := b.(*EnumValue); ok && x.Enum != y.Enum {
+			return false, x.Inspect() + " with " + y.Inspect()
+		}
+	}
+	if x, ok := a.(*String); ok {
+		if y, ok := b.(*String); ok {
+			return x.Value < y.Value, ""
+		}
+	}
+	x, okA := numberValue(a)
+	y, okB := numberValue(b)
+	if !okA || !okB {
+		return false, string(typeName(a)) + " with " + string(typeName(b))
+	}
+	return x < y, ""
+}
+
+func numberValue(obj Object) (float64, bool) {
+	switch v := obj.(type) {
+	case *Int:
+		return float64(v.Value), true
+	case *Real:
+		return v.Value, true
+	case *EnumValue:
+		return float64(v.Value), true
+	}
+	return 0, false
+}
+
+func arrayAndFunction(call *CallExpression, name string, args []Object, count int) (*Array, Object, Object) {
+	if len(args) != count {
+		return nil, nil, newError(call.Token, "%s expects %d arguments, got %d", name, count, len(args))
+	}
+	arr, ok := args[0].(*Array)
+	if !ok {
+		return nil, nil, newError(call.Token, "%s expects an array, got %s", name, typeName(args[0]))
+	}
+	if !isCallable(args[1]) {
+		return nil, nil, newError(call.Token, "%s expects a FRG_Fn, got %s", name, typeName(args[1]))
+	}
+	return arr, args[1], nil
+}
+
+func isCallable(obj Object) bool {
+	switch obj.(type) {
+	case *Function, *Builtin:
+		return true
+	}
+	return false
+}
+
+// callValue calls a FRG_Fn value from go, the builtins always need its value
+func callValue(fn Object, args []Object, call *CallExpression, env *Environment) Object {
+	switch fn := fn.(type) {
+	case *Function:
+		// map(arr, sum) gives sum its variadic array like sum(x) would
+		args, err := packArguments(fn, args, call)
+		if err != nil {
+			return err
+		}
+		result := applyFunction(fn, args, call, env)
+		if result == VOID {
+			return newError(call.Token, "%s is a FRG_Void function, it gives no value to use", fn.Name)
+		}
+		return result
+	case *Builtin:
+		return fn.Fn(call, env, args)
+	}
+	return newError(call.Token, "not a function: %s", typeName(fn))
+}
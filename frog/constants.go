@@ -0,0 +1,277 @@
+// Copyright (C) by abdenour souane
+// you have a right to modify it upgrade it or do whatever you want
+// but u have to keep my name on it
+
+package frog
+
+// checkConstants reports the assignments and the FRG_Input into a FRG_Const
+// or the name of a FRG_Enum, and the declarations that would hide one in the
+// scope it belongs to, before the program runs. it also checks the arguments
+// of the calls to a FRG_Fn it knows. the interpreter checks them again for what
+// it cannot see here (the debugger changing a variable for example)
+func (p *Parser) checkConstants(program *Program) {
+	p.checkConstStatements(program.Statements, newConstScope())
+}
+
+// constScope is what the check knows at one point of a file or a function:
+// the keyword that made a name read-only, FRG_Const or FRG_Enum, "" for a
+// variable, and the names declared by this file or function. a constant of
+// the file can be hidden by a local of a function, never where it was declared.
+// funcs are the names that can only be a FRG_Fn declared with a body
+type constScope struct {
+	names map[string]string
+	own   map[string]bool
+	funcs map[string]*FunctionDeclarationStatement
+}
+
+func newConstScope() *constScope {
+	return &constScope{
+		names: make(map[string]string),
+		own:   make(map[string]bool),
+		funcs: make(map[string]*FunctionDeclarationStatement),
+	}
+}
+
+// block gives the scope of an If or Repeat body, the interpreter runs it in the
+// scope of its function but a declaration inside may never run
+func (s *constScope) block() *constScope {
+	out := newConstScope()
+	for name, keyword := range s.names {
+		out.names[name] = keyword
+	}
+	for name := range s.own {
+		out.own[name] = true
+	}
+	for name, fn := range s.funcs {
+		out.funcs[name] = fn
+	}
+	return out
+}
+
+// function gives the scope of a function body, nothing of the file is its own
+func (s *constScope) function() *constScope {
+	out := s.block()
+	out.own = make(map[string]bool)
+	return out
+}
+
+// checkConstStatements goes through stmts in order, the bodies of the functions
+// run later and are checked with everything declared around them
+func (p *Parser) checkConstStatements(stmts []Statement, scope *constScope) {
+	var funcs []*FunctionDeclarationStatement
+	for _, stmt := range stmts {
+		funcs = p.checkConstStatement(stmt, scope, funcs)
+	}
+	for _, fn := range funcs {
+		p.checkConstFunction(fn, scope)
+	}
+}
+
+func (p *Parser) checkConstStatement(stmt Statement, scope *constScope, funcs []*FunctionDeclarationStatement) []*FunctionDeclarationStatement {
+	if isNilNode(stmt) {
+		// a broken statement, already reported
+		return funcs
+	}
+	switch s := stmt.(type) {
+	case *ExportStatement:
+		return p.checkConstStatement(s.Statement, scope, funcs)
+	case *ConstStatement:
+		for i, name := range s.Names {
+			p.checkConstExpressions(s.Values[i], scope)
+			p.declareConstName(name, s.Token.Literal, scope)
+		}
+	case *EnumStatement:
+		p.declareConstName(s.Name, s.Token.Literal, scope)
+	case *DeclarationStatement:
+		p.declareVariables(s, scope)
+	case *StaticStatement:
+		p.declareVariables(s.Declaration, scope)
+	case *UseStatement:
+		if s.Alias != nil {
+			p.declareConstName(s.Alias, "", scope)
+		}
+		for _, name := range s.Names {
+			p.declareConstName(name, exportedConst(s.Module, name.Value), scope)
+		}
+	case *FunctionDeclarationStatement:
+		if !s.Anonymous() {
+			p.declareConstName(s.Name, "", scope)
+			if s.Body != nil && !s.brokenParameters {
+				scope.funcs[s.Name.Value] = s
+			}
+		}
+		return append(funcs, s)
+	case *AssignmentStatement:
+		if ident, ok := s.Left.(*Identifier); ok && scope.names[ident.Value] != "" {
+			p.panicking = false
+			p.errorAt(ident.Token, "const", "cannot assign to %s, it is a %s", ident.Value, scope.names[ident.Value])
+		}
+		if ident, ok := s.Left.(*Identifier); ok {
+			// it may hold another function from now on
+			delete(scope.funcs, ident.Value)
+		}
+		p.checkConstExpressions(s, scope)
+	case *InputStatement:
+		for _, e := range s.Expressions {
+			if ident, ok := e.(*Identifier); ok && scope.names[ident.Value] != "" {
+				p.panicking = false
+				p.errorAt(ident.Token, "const", "cannot read %s with FRG_Input, it is a %s", ident.Value, scope.names[ident.Value])
+			}
+		}
+	case *IfStatement:
+		p.checkConstExpressions(s.Condition, scope)
+		p.checkConstStatements([]Statement{s.Consequence}, scope.block())
+		if !isNilNode(s.Alternative) {
+			p.checkConstStatements([]Statement{s.Alternative}, scope.block())
+		}
+	case *RepeatStatement:
+		body := scope.block()
+		p.checkConstStatements(s.Body, body)
+		// Until sees the declarations of the body
+		p.checkConstExpressions(s.Condition, body)
+	case *BlockStatement:
+		p.checkConstStatements(s.Statements, scope.block())
+	default:
+		p.checkConstExpressions(stmt, scope)
+	}
+	return funcs
+}
+
+// checkConstExpressions checks the bodies of the anonymous functions in node
+// and the calls to the functions of scope
+func (p *Parser) checkConstExpressions(node Node, scope *constScope) {
+	if isNilNode(node) {
+		return
+	}
+	inspect(node, func(n Node) bool {
+		switch n := n.(type) {
+		case *FunctionLiteral:
+			p.checkConstFunction(n.Function, scope)
+			return false
+		case *CallExpression:
+			if ident, ok := n.Function.(*Identifier); ok && scope.funcs[ident.Value] != nil {
+				p.checkCallArguments(n, scope.funcs[ident.Value])
+			}
+		}
+		return true
+	})
+}
+
+// checkCallArguments reports the named arguments fn does not have, the arguments
+// given twice and the required ones missing. parseCallArguments already reported
+// the same name given twice and a positional argument after a named one
+func (p *Parser) checkCallArguments(call *CallExpression, fn *FunctionDeclarationStatement) {
+	given := make([]bool, len(fn.Parameters))
+	positional := 0
+	for _, arg := range call.Arguments {
+		switch arg := arg.(type) {
+		case *SpreadArgument:
+			// the number of values is only known when it runs
+			return
+		case *NamedArgument:
+			index := -1
+			for i, param := range fn.Parameters {
+				if param.Name.Value == arg.Name.Value {
+					index = i
+				}
+			}
+			if index < 0 {
+				p.panicking = false
+				p.errorAt(arg.Name.Token, "named-argument", "%s has no parameter named %s", fn.Name.Value, arg.Name.Value)
+				continue
+			}
+			if index < positional {
+				p.panicking = false
+				p.errorAt(arg.Name.Token, "named-argument", "argument %s of %s is given twice", arg.Name.Value, fn.Name.Value)
+			}
+			given[index] = true
+		default:
+			if positional < len(given) {
+				given[positional] = true
+			}
+			positional++
+		}
+	}
+	fixed := len(fn.Parameters)
+	if fixed > 0 && fn.Parameters[fixed-1].Variadic {
+		return
+	}
+	if positional > fixed {
+		p.panicking = false
+		p.errorAt(startToken(call), "arguments", "wrong number of arguments: expected %d, got %d", fixed, positional)
+		return
+	}
+	for i, param := range fn.Parameters {
+		if !given[i] && param.Default == nil {
+			p.panicking = false
+			p.errorAt(startToken(call), "arguments", "missing argument %s of %s", param.Name.Value, fn.Name.Value)
+		}
+	}
+}
+
+func (p *Parser) checkConstFunction(fn *FunctionDeclarationStatement, outer *constScope) {
+	if fn.Body == nil {
+		return
+	}
+	scope := outer.function()
+	// the parameters and the return value hide the constants and the functions around
+	scope.names[fn.Name.Value] = ""
+	delete(scope.funcs, fn.Name.Value)
+	for _, param := range fn.Parameters {
+		if param.Default != nil {
+			p.checkConstExpressions(param.Default, scope)
+		}
+		scope.names[param.Name.Value] = ""
+		scope.own[param.Name.Value] = true
+		delete(scope.funcs, param.Name.Value)
+	}
+	p.checkConstStatements(fn.Body.Statements, scope)
+}
+
+func (p *Parser) declareVariables(ds *DeclarationStatement, scope *constScope) {
+	for i, ident := range ds.Identifiers {
+		if value := ds.value(i); value != nil {
+			p.checkConstExpressions(value, scope)
+		}
+		p.declareConstName(ident, "", scope)
+	}
+}
+
+// declareConstName records name, keyword is "" for a variable
+func (p *Parser) declareConstName(name *Identifier, keyword string, scope *constScope) {
+	if old := scope.names[name.Value]; old != "" && scope.own[name.Value] {
+		p.panicking = false
+		p.errorAt(name.Token, "const", "cannot declare %s again, it is a %s", name.Value, old)
+		return
+	}
+	scope.names[name.Value] = keyword
+	scope.own[name.Value] = true
+	delete(scope.funcs, name.Value)
+}
+
+// exportedConst gives the keyword of a FRG_Export FRG_Const or FRG_Enum
+// of name in module, "" if name is something else
+func exportedConst(module *Program, name string) string {
+	if module == nil {
+		return ""
+	}
+	for _, stmt := range module.Statements {
+		export, ok := stmt.(*ExportStatement)
+		if !ok {
+			continue
+		}
+		switch s := export.Statement.(type) {
+		case *ConstStatement:
+			for _, n := range s.Names {
+				if n.Value == name {
+					return s.Token.Literal
+				}
+			}
+		case *EnumStatement:
+			if s.Name.Value == name {
+				return s.Token.Literal
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,370 @@
+// Copyright (C) by abdenour souane
+// you have a right to modify it upgrade it or do whatever you want
+// but u have to keep my name on it
+
+package frog
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// coverage counts how many times every statement ran and every If went
+// to its consequence ("then") or not ("else", even without an Else block)
+// the counts are saved in a text profile so the runs of many programs add up:
+//
+//	mode: count
+//	std/std.frg:12:5 stmt 3
+//	std/std.frg:14:5 then 1
+//	std/std.frg:14:5 else 0
+
+type coverKind string
+
+const (
+	coverStatement coverKind = "stmt"
+	coverThen      coverKind = "then"
+	coverElse      coverKind = "else"
+)
+
+var coverOrder = map[coverKind]int{coverStatement: 0, coverThen: 1, coverElse: 2}
+
+type coverBlock struct {
+	File string
+	Line int
+	Col  int
+	Kind coverKind
+}
+
+type Coverage struct {
+	counts map[coverBlock]int64
+
+	// where every statement of the added programs is counted
+	stmts    map[Statement]coverBlock
+	branches map[*IfStatement]coverBlock // the "then" block, "else" has the same position
+}
+
+func NewCoverage() *Coverage {
+	return &Coverage{
+		counts:   make(map[coverBlock]int64),
+		stmts:    make(map[Statement]coverBlock),
+		branches: make(map[*IfStatement]coverBlock),
+	}
+}
+
+// WithCoverage counts the statements and branches run in c,
+// the program must be added first (Coverage.Add)
+func WithCoverage(c *Coverage) Option {
+	return func(rt *Runtime) {
+		rt.Coverage = c
+	}
+}
+
+// Add registers every statement of program (FRG_Use files included) so
+// the ones never run are reported too
+func (c *Coverage) Add(program *Program) {
+	c.add(program)
+}
+
+func (c *Coverage) add(node Node) {
+	inspect(node, func(n Node) bool {
+		if lit, ok := n.(*FunctionLiteral); ok {
+			// the declaration inside an anonymous function never runs, its body does
+			c.add(lit.Function.Body)
+			return false
+		}
+		stmt, ok := n.(Statement)
+		if !ok {
+			return true
+		}
+		switch stmt.(type) {
+		case *BlockStatement, *ExportStatement:
+			// only what is inside runs as a statement
+			return true
+		}
+		tok := startToken(stmt)
+		block := coverBlock{tok.File, tok.Line, tok.Column, coverStatement}
+		c.stmts[stmt] = block
+		c.counts[block] += 0
+		if is, ok := stmt.(*IfStatement); ok {
+			block.Kind = coverThen
+			c.branches[is] = block
+			c.counts[block] += 0
+			block.Kind = coverElse
+			c.counts[block] += 0
+		}
+		return true
+	})
+}
+
+func (c *Coverage) statement(stmt Statement) {
+	if block, ok := c.stmts[stmt]; ok {
+		c.counts[block]++
+	}
+}
+
+func (c *Coverage) branch(is *IfStatement, taken bool) {
+	block, ok := c.branches[is]
+	if !ok {
+		return
+	}
+	if !taken {
+		block.Kind = coverElse
+	}
+	c.counts[block]++
+}
+
+// WriteProfile saves the counts, ReadProfile adds them back
+func (c *Coverage) WriteProfile(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "mode: count")
+	for _, block := range c.sortedBlocks() {
+		fmt.Fprintf(bw, "%s:%d:%d %s %d\n", block.File, block.Line, block.Col, block.Kind, c.counts[block])
+	}
+	return bw.Flush()
+}
+
+// ReadProfile adds the counts of a profile written by WriteProfile
+func (c *Coverage) ReadProfile(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+		block, count, ok := parseCoverLine(line)
+		if !ok {
+			return fmt.Errorf("line %d: bad coverage line %q", lineNumber, line)
+		}
+		c.counts[block] += count
+	}
+	return scanner.Err()
+}
+
+// parseCoverLine reads "file:line:col kind count", the file may contain spaces and colons
+func parseCoverLine(line string) (coverBlock, int64, bool) {
+	space := strings.LastIndexByte(line, ' ')
+	if space < 0 {
+		return coverBlock{}, 0, false
+	}
+	count, err := strconv.ParseInt(line[space+1:], 10, 64)
+	if err != nil {
+		return coverBlock{}, 0, false
+	}
+	line = line[:space]
+	space = strings.LastIndexByte(line, ' ')
+	if space < 0 {
+		return coverBlock{}, 0, false
+	}
+	kind := coverKind(line[space+1:])
+	if kind != coverStatement && kind != coverThen && kind != coverElse {
+		return coverBlock{}, 0, false
+	}
+	pos := line[:space]
+	colon := strings.LastIndexByte(pos, ':')
+	if colon < 0 {
+		return coverBlock{}, 0, false
+	}
+	col, err := strconv.Atoi(pos[colon+1:])
+	if err != nil {
+		return coverBlock{}, 0, false
+	}
+	pos = pos[:colon]
+	colon = strings.LastIndexByte(pos, ':')
+	if colon < 0 {
+		return coverBlock{}, 0, false
+	}
+	ln, err := strconv.Atoi(pos[colon+1:])
+	if err != nil {
+		return coverBlock{}, 0, false
+	}
+	return coverBlock{pos[:colon], ln, col, kind}, count, true
+}
+
+func (c *Coverage) sortedBlocks() []coverBlock {
+	blocks := make([]coverBlock, 0, len(c.counts))
+	for block := range c.counts {
+		blocks = append(blocks, block)
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		a, b := blocks[i], blocks[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Col != b.Col {
+			return a.Col < b.Col
+		}
+		return coverOrder[a.Kind] < coverOrder[b.Kind]
+	})
+	return blocks
+}
+
+// coverFile is what the reports show for one source file
+type coverFile struct {
+	name               string
+	statements, ran    int
+	branches, branched int
+	lines              map[int]*coverLine
+}
+
+type coverLine struct {
+	count      int64 // times the line ran (its first statement)
+	statements int
+	ran        int
+	missed     []coverKind // branches never taken
+}
+
+func (f *coverFile) percent() (float64, float64) {
+	return percent(f.ran, f.statements), percent(f.branched, f.branches)
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return 100 * float64(n) / float64(total)
+}
+
+func (c *Coverage) files() []*coverFile {
+	byName := make(map[string]*coverFile)
+	var files []*coverFile
+	for _, block := range c.sortedBlocks() {
+		f, ok := byName[block.File]
+		if !ok {
+			f = &coverFile{name: block.File, lines: make(map[int]*coverLine)}
+			byName[block.File] = f
+			files = append(files, f)
+		}
+		line, ok := f.lines[block.Line]
+		if !ok {
+			line = &coverLine{count: -1}
+			f.lines[block.Line] = line
+		}
+		count := c.counts[block]
+		if block.Kind == coverStatement {
+			f.statements++
+			line.statements++
+			if line.count < 0 {
+				line.count = count
+			}
+			if count > 0 {
+				f.ran++
+				line.ran++
+			}
+			continue
+		}
+		f.branches++
+		if count > 0 {
+			f.branched++
+		} else {
+			line.missed = append(line.missed, block.Kind)
+		}
+	}
+	return files
+}
+
+// WriteSummary writes the covered statements and branches of every file
+func (c *Coverage) WriteSummary(w io.Writer) {
+	files := c.files()
+	width := len("total")
+	for _, f := range files {
+		if len(f.name) > width {
+			width = len(f.name)
+		}
+	}
+	fmt.Fprintf(w, "%-*s  %17s  %17s\n", width, "file", "statements", "branches")
+	total := &coverFile{name: "total"}
+	for _, f := range append(files, total) {
+		if f != total {
+			total.statements += f.statements
+			total.ran += f.ran
+			total.branches += f.branches
+			total.branched += f.branched
+		}
+		stmts, branches := f.percent()
+		fmt.Fprintf(w, "%-*s  %9s %6.1f%%  %9s %6.1f%%\n", width, f.name,
+			fmt.Sprintf("%d/%d", f.ran, f.statements), stmts,
+			fmt.Sprintf("%d/%d", f.branched, f.branches), branches)
+	}
+}
+
+// WriteHTML writes a page with the source of every file, the lines that ran
+// are green, the ones that never ran red and the ones partly run yellow
+func (c *Coverage) WriteHTML(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprint(bw, coverHTMLHead)
+	files := c.files()
+
+	fmt.Fprintln(bw, `<table class="summary"><tr><th>file</th><th>statements</th><th>branches</th></tr>`)
+	for i, f := range files {
+		stmts, branches := f.percent()
+		fmt.Fprintf(bw, "<tr><td><a href=\"#file%d\">%s</a></td><td>%d/%d (%.1f%%)</td><td>%d/%d (%.1f%%)</td></tr>\n",
+			i, html.EscapeString(f.name), f.ran, f.statements, stmts, f.branched, f.branches, branches)
+	}
+	fmt.Fprintln(bw, "</table>")
+
+	for i, f := range files {
+		fmt.Fprintf(bw, "<h2 id=\"file%d\">%s</h2>\n<pre>", i, html.EscapeString(f.name))
+		content, err := ReadSource(f.name)
+		if err != nil {
+			fmt.Fprintf(bw, "source not found: %s</pre>\n", html.EscapeString(err.Error()))
+			continue
+		}
+		for n, text := range strings.Split(strings.TrimRight(string(content), "\n"), "\n") {
+			class, count, title := "", "", ""
+			if line, ok := f.lines[n+1]; ok && line.statements > 0 {
+				count = strconv.FormatInt(line.count, 10)
+				switch {
+				case line.ran == 0:
+					class = "miss"
+				case line.ran < line.statements || len(line.missed) > 0:
+					class = "partial"
+				default:
+					class = "hit"
+				}
+				if len(line.missed) > 0 {
+					missed := []string{}
+					for _, kind := range line.missed {
+						missed = append(missed, string(kind))
+					}
+					title = " title=\"branch never taken: " + strings.Join(missed, ", ") + "\""
+				}
+			}
+			fmt.Fprintf(bw, "<span class=\"line %s\"%s><span class=\"n\">%5d</span><span class=\"c\">%6s</span> %s</span>\n",
+				class, title, n+1, count, html.EscapeString(strings.TrimRight(text, "\r")))
+		}
+		fmt.Fprintln(bw, "</pre>")
+	}
+	fmt.Fprint(bw, "</body>\n</html>\n")
+	return bw.Flush()
+}
+
+const coverHTMLHead = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>frog coverage</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table.summary { border-collapse: collapse; }
+table.summary td, table.summary th { padding: 2px 12px; text-align: left; }
+pre { background: #fafafa; border: 1px solid #ddd; padding: 8px; }
+.line { display: block; }
+.n, .c { color: #888; user-select: none; }
+.hit { background: #dfd; }
+.miss { background: #fdd; }
+.partial { background: #ffc; }
+</style>
+</head>
+<body>
+<h1>frog coverage</h1>
+`
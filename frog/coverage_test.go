@@ -0,0 +1,132 @@
+// Copyright (C) by abdenour souane
+// you have a right to modify it upgrade it or do whatever you want
+// but u have to keep my name on it
+
+package frog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// parseSource parses code as the file name, it must have no errors
+func parseSource(t *testing.T, name, code string) *Program {
+	t.Helper()
+	parser := NewParser(NewFileLexer(name, code))
+	program := parser.ParseProgram()
+	if parser.IsThereAnyErrors() {
+		t.Fatalf("%s does not parse: %s", name, parser.Diagnostics()[0].Message)
+	}
+	return program
+}
+
+// runProgram runs program with opts, what it prints is returned
+func runProgram(t *testing.T, program *Program, opts ...Option) string {
+	t.Helper()
+	var out bytes.Buffer
+	env := NewEnvironment(opts...)
+	env.Runtime().Out = &out
+	if err, ok := Eval(program, env).(*Error); ok {
+		t.Fatal(err.Inspect())
+	}
+	return out.String()
+}
+
+// four statements run: the constant, the function, the print and the body
+const exportSource = `FRG_Begin
+    FRG_Export FRG_Const FRG_Int SIZE := 2#
+    FRG_Export FRG_Fn twice(FRG_Int x) : FRG_Int
+    Begin
+        twice := x * SIZE#
+    End
+    FRG_Print twice(3)#
+FRG_End
+`
+
+// an exported statement is one statement, not the FRG_Export and what it exports
+func TestCoverageExport(t *testing.T) {
+	program := parseSource(t, "export.frg", exportSource)
+	c := NewCoverage()
+	c.Add(program)
+	runProgram(t, program, WithCoverage(c), WithMaxSteps(4))
+	var out bytes.Buffer
+	if err := c.WriteProfile(&out); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"mode: count",
+		"export.frg:2:16 stmt 1",
+		"export.frg:3:16 stmt 1",
+		"export.frg:5:9 stmt 1",
+		"export.frg:7:5 stmt 1",
+	}, "\n")
+	if got := strings.TrimSpace(out.String()); got != want {
+		t.Errorf("profile\n%s\nwant\n%s", got, want)
+	}
+}
+
+const branchSource = `FRG_Begin
+    FRG_Int x#
+    x := 3#
+    If [x > 5]
+    Begin
+        FRG_Print "big"#
+    End
+    Else
+    Begin
+        FRG_Print "small"#
+    End
+FRG_End
+`
+
+// a profile read back gives the same profile, and the counts of several runs add up
+func TestCoverageProfile(t *testing.T) {
+	// the file name has a space and a colon, the count is after the last space
+	program := parseSource(t, "my dir:1/branch.frg", branchSource)
+	c := NewCoverage()
+	c.Add(program)
+	runProgram(t, program, WithCoverage(c))
+	var first bytes.Buffer
+	if err := c.WriteProfile(&first); err != nil {
+		t.Fatal(err)
+	}
+
+	back := NewCoverage()
+	for i := 0; i < 2; i++ {
+		if err := back.ReadProfile(bytes.NewReader(first.Bytes())); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var second bytes.Buffer
+	if err := back.WriteProfile(&second); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"mode: count",
+		"my dir:1/branch.frg:2:5 stmt 2",
+		"my dir:1/branch.frg:3:5 stmt 2",
+		"my dir:1/branch.frg:4:5 stmt 2",
+		"my dir:1/branch.frg:4:5 then 0",
+		"my dir:1/branch.frg:4:5 else 2",
+		"my dir:1/branch.frg:6:9 stmt 0",
+		"my dir:1/branch.frg:10:9 stmt 2",
+	}, "\n")
+	if got := strings.TrimSpace(second.String()); got != want {
+		t.Errorf("profile read twice\n%s\nwant\n%s", got, want)
+	}
+
+	var summary bytes.Buffer
+	back.WriteSummary(&summary)
+	wantSummary := `file                        statements           branches
+my dir:1/branch.frg        4/5   80.0%        1/2   50.0%
+total                      4/5   80.0%        1/2   50.0%
+`
+	if summary.String() != wantSummary {
+		t.Errorf("summary\n%s\nwant\n%s", summary.String(), wantSummary)
+	}
+
+	if err := NewCoverage().ReadProfile(strings.NewReader("mode: count\nbranch.frg:2 stmt 1\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("got %v, want an error on line 2", err)
+	}
+}
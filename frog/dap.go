@@ -0,0 +1,712 @@
+// Copyright (C) by abdenour souane
+// you have a right to modify it upgrade it or do whatever you want
+// but u have to keep my name on it
+
+package frog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// debug adapter protocol : https://microsoft.github.io/debug-adapter-protocol/
+// the messages are framed like the language server ones (see jsonrpc.go)
+// the program runs in its own goroutine, the hook blocks it while it is stopped
+// and the requests of the client are answered from the main loop
+
+type dapRequest struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+type dapResponse struct {
+	Seq        int         `json:"seq"`
+	Type       string      `json:"type"`
+	RequestSeq int         `json:"request_seq"`
+	Success    bool        `json:"success"`
+	Command    string      `json:"command"`
+	Message    string      `json:"message,omitempty"`
+	Body       interface{} `json:"body,omitempty"`
+}
+
+type dapEvent struct {
+	Seq   int         `json:"seq"`
+	Type  string      `json:"type"`
+	Event string      `json:"event"`
+	Body  interface{} `json:"body,omitempty"`
+}
+
+type dapSource struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+type dapBreakpoint struct {
+	ID       int        `json:"id,omitempty"`
+	Verified bool       `json:"verified"`
+	Line     int        `json:"line,omitempty"`
+	Message  string     `json:"message,omitempty"`
+	Source   *dapSource `json:"source,omitempty"`
+}
+
+type dapStackFrame struct {
+	ID     int        `json:"id"`
+	Name   string     `json:"name"`
+	Source *dapSource `json:"source,omitempty"`
+	Line   int        `json:"line"`
+	Column int        `json:"column"`
+}
+
+type dapScope struct {
+	Name               string `json:"name"`
+	VariablesReference int    `json:"variablesReference"`
+	Expensive          bool   `json:"expensive"`
+}
+
+type dapVariable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	Type               string `json:"type,omitempty"`
+	VariablesReference int    `json:"variablesReference"`
+}
+
+// frog programs have a single thread
+const dapThreadID = 1
+
+// dapFrame is a frame of the stopped program, the innermost first
+type dapFrame struct {
+	name     string
+	at       Token
+	env      *Environment
+	function *Function // nil for the program itself
+}
+
+type dapServer struct {
+	reader *bufio.Reader
+
+	writeLock sync.Mutex
+	writer    io.Writer
+	seq       int
+
+	// everything below is shared with the program goroutine
+	lock    sync.Mutex
+	stepper stepper
+	program *Program
+	file    string // absolute path of the launched file
+	global  *Environment
+	started bool
+
+	stopped  bool
+	entry    bool // stop before the first statement (stopOnEntry)
+	pausing  bool
+	aborting bool
+	frames   []dapFrame
+	refs     map[int]func() []dapVariable // variablesReference -> children, valid while stopped
+	nextRef  int
+	resumeCh chan bool // false to abort the program
+	done     chan struct{}
+
+	linesStartAt1   bool
+	columnsStartAt1 bool
+	sources         map[string][]string
+}
+
+// ServeDAP runs the frog debug adapter over in/out (stdin/stdout for `frog dap`)
+// until the client disconnects or closes the input
+func ServeDAP(in io.Reader, out io.Writer) error {
+	s := &dapServer{
+		reader:          bufio.NewReader(in),
+		writer:          out,
+		stepper:         newStepper(),
+		resumeCh:        make(chan bool),
+		done:            make(chan struct{}),
+		linesStartAt1:   true,
+		columnsStartAt1: true,
+		sources:         make(map[string][]string),
+	}
+	for {
+		body, err := readMessage(s.reader)
+		if err == io.EOF {
+			s.abort()
+			return nil
+		}
+		if err != nil {
+			s.abort()
+			return err
+		}
+
+		var req dapRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return fmt.Errorf("malformed message: %v", err)
+		}
+		if req.Type != "request" {
+			continue
+		}
+
+		result, err := s.handle(&req)
+		resp := dapResponse{Type: "response", RequestSeq: req.Seq, Command: req.Command, Success: err == nil, Body: result}
+		if err != nil {
+			resp.Message = err.Error()
+		}
+		werr := s.send(&resp)
+		if err == nil && resuming(req.Command) {
+			// the client gets the response before the program can stop again
+			s.resumeCh <- true
+		}
+		if werr != nil {
+			return werr
+		}
+
+		switch req.Command {
+		case "launch":
+			// breakpoints can only be placed once the program is parsed
+			if err == nil {
+				s.event("initialized", nil)
+			}
+		case "configurationDone":
+			s.start()
+		case "disconnect", "terminate":
+			s.abort()
+			if req.Command == "disconnect" {
+				return nil
+			}
+		}
+	}
+}
+
+// resuming tells the requests that let the stopped program run
+func resuming(command string) bool {
+	switch command {
+	case "continue", "next", "stepIn", "stepOut":
+		return true
+	}
+	return false
+}
+
+// send writes a response or an event, it sets its sequence number
+func (s *dapServer) send(msg interface{}) error {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	s.seq++
+	switch m := msg.(type) {
+	case *dapResponse:
+		m.Seq = s.seq
+	case *dapEvent:
+		m.Seq = s.seq
+	}
+	return writeMessage(s.writer, msg)
+}
+
+func (s *dapServer) event(name string, body interface{}) {
+	s.send(&dapEvent{Type: "event", Event: name, Body: body})
+}
+
+func (s *dapServer) handle(req *dapRequest) (interface{}, error) {
+	switch req.Command {
+	case "initialize":
+		var args struct {
+			LinesStartAt1   *bool `json:"linesStartAt1"`
+			ColumnsStartAt1 *bool `json:"columnsStartAt1"`
+		}
+		json.Unmarshal(req.Arguments, &args)
+		if args.LinesStartAt1 != nil {
+			s.linesStartAt1 = *args.LinesStartAt1
+		}
+		if args.ColumnsStartAt1 != nil {
+			s.columnsStartAt1 = *args.ColumnsStartAt1
+		}
+		return map[string]interface{}{
+			"supportsConfigurationDoneRequest": true,
+			"supportsEvaluateForHovers":        true,
+			"supportsTerminateRequest":         true,
+		}, nil
+	case "launch":
+		return nil, s.launch(req.Arguments)
+	case "setBreakpoints":
+		return s.setBreakpoints(req.Arguments)
+	case "setExceptionBreakpoints":
+		return map[string]interface{}{"breakpoints": []dapBreakpoint{}}, nil
+	case "configurationDone", "disconnect", "terminate":
+		return nil, nil
+	case "threads":
+		return map[string]interface{}{
+			"threads": []map[string]interface{}{{"id": dapThreadID, "name": "main"}},
+		}, nil
+	case "stackTrace":
+		return s.stackTrace()
+	case "scopes":
+		return s.scopes(req.Arguments)
+	case "variables":
+		return s.variables(req.Arguments)
+	case "evaluate":
+		return s.evaluate(req.Arguments)
+	case "continue":
+		return map[string]interface{}{"allThreadsContinued": true}, s.resume(debugContinue)
+	case "next":
+		return nil, s.resume(debugNext)
+	case "stepIn":
+		return nil, s.resume(debugStep)
+	case "stepOut":
+		return nil, s.resume(debugOut)
+	case "pause":
+		s.lock.Lock()
+		s.stepper.mode = debugStep
+		s.pausing = true
+		s.lock.Unlock()
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported request %s", req.Command)
+}
+
+func (s *dapServer) launch(raw json.RawMessage) error {
+	var args struct {
+		Program     string `json:"program"`
+		Cwd         string `json:"cwd"`
+		StopOnEntry bool   `json:"stopOnEntry"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return err
+	}
+	if args.Program == "" {
+		return fmt.Errorf("launch needs the program to debug")
+	}
+	if args.Cwd != "" {
+		// FRG_Use files are read relative to the working directory
+		if err := os.Chdir(args.Cwd); err != nil {
+			return err
+		}
+	}
+	file, err := filepath.Abs(args.Program)
+	if err != nil {
+		return err
+	}
+	code, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	parser := NewParser(NewFileLexer(file, string(code)))
+	program := parser.ParseProgram()
+	if parser.IsThereAnyErrors() {
+		var out strings.Builder
+		for _, d := range parser.Diagnostics() {
+			out.WriteString(d.Render(string(code)))
+		}
+		s.event("output", map[string]interface{}{"category": "stderr", "output": out.String()})
+		return fmt.Errorf("%s has syntax errors", args.Program)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.program = program
+	s.file = file
+	s.entry = args.StopOnEntry
+	s.sources[file] = strings.Split(string(code), "\n")
+	s.stepper.index(program)
+	return nil
+}
+
+// the client and the tokens may name the same file differently
+func (s *dapServer) sameFile(tokenFile, clientPath string) bool {
+	a, err1 := filepath.Abs(tokenFile)
+	b, err2 := filepath.Abs(clientPath)
+	return err1 == nil && err2 == nil && a == b
+}
+
+func (s *dapServer) setBreakpoints(raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		Source      dapSource `json:"source"`
+		Breakpoints []struct {
+			Line int `json:"line"`
+		} `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// the request replaces every breakpoint of the file
+	file := ""
+	for f := range s.stepper.stmts {
+		if s.sameFile(f, args.Source.Path) {
+			file = f
+		}
+	}
+	kept := s.stepper.breakpoints[:0]
+	for _, bp := range s.stepper.breakpoints {
+		if bp.file != file {
+			kept = append(kept, bp)
+		}
+	}
+	s.stepper.breakpoints = kept
+
+	result := []dapBreakpoint{}
+	for _, requested := range args.Breakpoints {
+		bp, ok := s.stepper.addBreakpoint(file, s.fromClientLine(requested.Line))
+		if file == "" || !ok {
+			result = append(result, dapBreakpoint{Verified: false, Line: requested.Line, Message: "no statement at or after this line"})
+			continue
+		}
+		result = append(result, dapBreakpoint{
+			ID:       bp.id,
+			Verified: true,
+			Line:     s.toClientLine(bp.line),
+			Source:   s.source(bp.file),
+		})
+	}
+	return map[string]interface{}{"breakpoints": result}, nil
+}
+
+// start runs the launched program in its own goroutine
+func (s *dapServer) start() {
+	s.lock.Lock()
+	if s.program == nil || s.started {
+		s.lock.Unlock()
+		return
+	}
+	s.started = true
+	if s.entry {
+		s.stepper.mode = debugStep
+	} else {
+		s.stepper.mode = debugContinue
+	}
+	env := NewEnvironment()
+	rt := env.Runtime()
+	rt.Hook = s.hook
+	rt.Out = dapOutput{s}
+	// stdin carries the protocol, FRG_Input gets end of file
+	rt.In = bufio.NewReader(strings.NewReader(""))
+	s.global = env
+	program := s.program
+	s.lock.Unlock()
+
+	go func() {
+		defer close(s.done)
+		result := s.run(program, env)
+		exitCode := 0
+		if err, ok := result.(*Error); ok {
+			s.event("output", map[string]interface{}{"category": "stderr", "output": err.Inspect() + "\n" + err.Traceback()})
+			exitCode = 1
+		}
+		s.event("exited", map[string]interface{}{"exitCode": exitCode})
+		s.event("terminated", nil)
+	}()
+}
+
+func (s *dapServer) run(program *Program, env *Environment) (result Object) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(debugQuit); !ok {
+				panic(r)
+			}
+			result = nil
+		}
+	}()
+	return Eval(program, env)
+}
+
+// hook runs in the program goroutine before every statement
+func (s *dapServer) hook(stmt Statement, env *Environment) {
+	s.lock.Lock()
+	if s.aborting {
+		s.lock.Unlock()
+		panic(debugQuit{})
+	}
+	stop, bp := s.stepper.shouldStop(stmt, env)
+	if !stop {
+		s.lock.Unlock()
+		return
+	}
+
+	reason := "step"
+	switch {
+	case bp != nil:
+		reason = "breakpoint"
+	case s.entry:
+		reason = "entry"
+	case s.pausing:
+		reason = "pause"
+	}
+	s.entry = false
+	s.pausing = false
+
+	s.stopped = true
+	s.frames = s.collectFrames(startToken(stmt), env)
+	s.refs = make(map[int]func() []dapVariable)
+	s.nextRef = 0
+	s.lock.Unlock()
+
+	body := map[string]interface{}{"reason": reason, "threadId": dapThreadID, "allThreadsStopped": true}
+	if bp != nil {
+		body["hitBreakpointIds"] = []int{bp.id}
+	}
+	s.event("stopped", body)
+
+	if !<-s.resumeCh {
+		panic(debugQuit{})
+	}
+}
+
+func (s *dapServer) collectFrames(at Token, env *Environment) []dapFrame {
+	frames := []dapFrame{}
+	calls := env.Runtime().Frames
+	for i := len(calls) - 1; i >= 0; i-- {
+		frames = append(frames, dapFrame{name: calls[i].Function.Name, at: at, env: calls[i].Env, function: calls[i].Function})
+		at = startToken(calls[i].Call)
+	}
+	return append(frames, dapFrame{name: "FRG_Begin", at: at, env: s.global})
+}
+
+// resume prepares the stopped program to run until the stepper stops it again,
+// ServeDAP releases it once the response is written
+func (s *dapServer) resume(mode debugMode) error {
+	s.lock.Lock()
+	if !s.stopped {
+		s.lock.Unlock()
+		return fmt.Errorf("the program is not stopped")
+	}
+	s.stepper.resume(mode, s.frames[0].env)
+	s.stopped = false
+	s.frames = nil
+	s.refs = nil
+	s.lock.Unlock()
+	return nil
+}
+
+// abort stops the program (if it runs) and waits for it
+func (s *dapServer) abort() {
+	s.lock.Lock()
+	started, stopped := s.started, s.stopped
+	s.aborting = true // the hook stops it at the next statement
+	s.stopped = false
+	s.lock.Unlock()
+	if !started {
+		return
+	}
+	if stopped {
+		s.resumeCh <- false
+	}
+	<-s.done
+}
+
+func (s *dapServer) stackTrace() (interface{}, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.stopped {
+		return nil, fmt.Errorf("the program is not stopped")
+	}
+	frames := []dapStackFrame{}
+	for i, f := range s.frames {
+		frames = append(frames, dapStackFrame{
+			ID:     i,
+			Name:   f.name,
+			Source: s.source(f.at.File),
+			Line:   s.toClientLine(f.at.Line),
+			Column: s.toClientColumn(f.at),
+		})
+	}
+	return map[string]interface{}{"stackFrames": frames, "totalFrames": len(frames)}, nil
+}
+
+func (s *dapServer) frame(id int) (dapFrame, error) {
+	if !s.stopped {
+		return dapFrame{}, fmt.Errorf("the program is not stopped")
+	}
+	if id < 0 || id >= len(s.frames) {
+		return dapFrame{}, fmt.Errorf("unknown frame %d", id)
+	}
+	return s.frames[id], nil
+}
+
+func (s *dapServer) scopes(raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		FrameID int `json:"frameId"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	f, err := s.frame(args.FrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := []dapScope{}
+	if f.function != nil {
+		// a call copies the globals, its locals are the parameters,
+		// the return slot and what the body declared
+		locals := map[string]Object{}
+		for name, val := range f.env.store {
+			if _, global := f.function.Env.store[name]; !global || name == f.function.Name {
+				locals[name] = val
+			}
+		}
+		for _, param := range f.function.Parameters {
+			locals[param.Name.Value] = f.env.store[param.Name.Value]
+		}
+		scopes = append(scopes, dapScope{Name: "Locals", VariablesReference: s.reference(locals)})
+		if len(f.function.Statics) > 0 {
+			scopes = append(scopes, dapScope{Name: "Statics", VariablesReference: s.reference(f.function.Statics)})
+		}
+	}
+	scopes = append(scopes, dapScope{Name: "Globals", VariablesReference: s.reference(s.global.store)})
+	return map[string]interface{}{"scopes": scopes}, nil
+}
+
+// reference registers the variables of a scope, they are listed when the client asks
+func (s *dapServer) reference(store map[string]Object) int {
+	s.nextRef++
+	s.refs[s.nextRef] = func() []dapVariable {
+		names := make([]string, 0, len(store))
+		for name, val := range store {
+			if _, isFn := val.(*Function); !isFn {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		vars := []dapVariable{}
+		for _, name := range names {
+			vars = append(vars, s.variable(name, store[name]))
+		}
+		return vars
+	}
+	return s.nextRef
+}
+
+// variable describes a value, arrays can be expanded
+func (s *dapServer) variable(name string, val Object) dapVariable {
+	v := dapVariable{Name: name, Value: inspectValue(val)}
+	if val == nil {
+		return v
+	}
+	v.Type = string(val.Type())
+	if array, ok := val.(*Array); ok && len(array.Elements) > 0 {
+		s.nextRef++
+		s.refs[s.nextRef] = func() []dapVariable {
+			vars := []dapVariable{}
+			for i, el := range array.Elements {
+				vars = append(vars, s.variable(fmt.Sprintf("[%d]", i), el))
+			}
+			return vars
+		}
+		v.VariablesReference = s.nextRef
+	}
+	return v
+}
+
+func (s *dapServer) variables(raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		VariablesReference int `json:"variablesReference"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	list, ok := s.refs[args.VariablesReference]
+	if !s.stopped || !ok {
+		return nil, fmt.Errorf("unknown variables reference %d", args.VariablesReference)
+	}
+	return map[string]interface{}{"variables": list()}, nil
+}
+
+func (s *dapServer) evaluate(raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		Expression string `json:"expression"`
+		FrameID    *int   `json:"frameId"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	expr, err := parseDebugExpression(args.Expression)
+	if err != nil {
+		return nil, err
+	}
+	id := 0
+	if args.FrameID != nil {
+		id = *args.FrameID
+	}
+
+	s.lock.Lock()
+	f, err := s.frame(id)
+	if err != nil {
+		s.lock.Unlock()
+		return nil, err
+	}
+	// the hook takes the lock if the expression calls a FRG_Fn
+	s.stepper.evaluating = true
+	s.lock.Unlock()
+	val := Eval(expr, f.env)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.stepper.evaluating = false
+
+	if isError(val) {
+		return nil, fmt.Errorf("%s", val.(*Error).Message)
+	}
+	v := s.variable(args.Expression, val)
+	return map[string]interface{}{"result": v.Value, "type": v.Type, "variablesReference": v.VariablesReference}, nil
+}
+
+func (s *dapServer) source(file string) *dapSource {
+	path, err := filepath.Abs(file)
+	if err != nil {
+		path = file
+	}
+	return &dapSource{Name: filepath.Base(file), Path: path}
+}
+
+func (s *dapServer) toClientLine(line int) int {
+	if s.linesStartAt1 {
+		return line
+	}
+	return line - 1
+}
+
+func (s *dapServer) fromClientLine(line int) int {
+	if s.linesStartAt1 {
+		return line
+	}
+	return line + 1
+}
+
+// frog columns count runes, clients count UTF-16 units like the language server
+func (s *dapServer) toClientColumn(tok Token) int {
+	lines, ok := s.sources[tok.File]
+	if !ok {
+		if content, err := ReadSource(tok.File); err == nil {
+			lines = strings.Split(string(content), "\n")
+		}
+		s.sources[tok.File] = lines
+	}
+	col := tok.Column - 1
+	if tok.Line >= 1 && tok.Line <= len(lines) {
+		col = utf16Offset(lines[tok.Line-1], tok.Column)
+	}
+	if s.columnsStartAt1 {
+		return col + 1
+	}
+	return col
+}
+
+// dapOutput sends what the program prints as output events
+type dapOutput struct {
+	s *dapServer
+}
+
+func (o dapOutput) Write(p []byte) (int, error) {
+	o.s.event("output", map[string]interface{}{"category": "stdout", "output": string(p)})
+	return len(p), nil
+}
@@ -0,0 +1,204 @@
+// Copyright (C) by abdenour souane
+// you have a right to modify it upgrade it or do whatever you want
+// but u have to keep my name on it
+
+package frog
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// dapClient talks to a ServeDAP running in the test over pipes
+type dapClient struct {
+	t      *testing.T
+	writer *io.PipeWriter
+	reader *bufio.Reader
+	seq    int
+	served chan error
+}
+
+func newDAPClient(t *testing.T) *dapClient {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &dapClient{t: t, writer: inW, reader: bufio.NewReader(outR), served: make(chan error, 1)}
+	go func() {
+		err := ServeDAP(inR, outW)
+		outW.Close()
+		c.served <- err
+	}()
+	return c
+}
+
+// next reads the next message the server wrote
+func (c *dapClient) next() map[string]interface{} {
+	c.t.Helper()
+	read := make(chan []byte, 1)
+	go func() {
+		body, err := readMessage(c.reader)
+		if err != nil {
+			body = nil
+		}
+		read <- body
+	}()
+	select {
+	case body := <-read:
+		if body == nil {
+			c.t.Fatal("the server closed its output")
+		}
+		var msg map[string]interface{}
+		if err := json.Unmarshal(body, &msg); err != nil {
+			c.t.Fatal(err)
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("no message from the server")
+	}
+	return nil
+}
+
+// request sends a request, its response must be the next message
+func (c *dapClient) request(command string, args interface{}) map[string]interface{} {
+	c.t.Helper()
+	c.seq++
+	if err := writeMessage(c.writer, map[string]interface{}{"seq": c.seq, "type": "request", "command": command, "arguments": args}); err != nil {
+		c.t.Fatal(err)
+	}
+	msg := c.next()
+	if msg["type"] != "response" || msg["command"] != command || msg["request_seq"] != float64(c.seq) {
+		c.t.Fatalf("%s: got %v, want its response", command, msg)
+	}
+	if msg["success"] != true {
+		c.t.Fatalf("%s failed: %v", command, msg["message"])
+	}
+	body, _ := msg["body"].(map[string]interface{})
+	return body
+}
+
+// event reads the next message, it must be the event
+func (c *dapClient) event(name string) map[string]interface{} {
+	c.t.Helper()
+	msg := c.next()
+	if msg["type"] != "event" || msg["event"] != name {
+		c.t.Fatalf("got %v, want the %s event", msg, name)
+	}
+	body, _ := msg["body"].(map[string]interface{})
+	return body
+}
+
+// list returns the elements of a body field, each one an object
+func list(t *testing.T, body map[string]interface{}, field string) []map[string]interface{} {
+	t.Helper()
+	items, ok := body[field].([]interface{})
+	if !ok {
+		t.Fatalf("no %s in %v", field, body)
+	}
+	result := []map[string]interface{}{}
+	for _, item := range items {
+		result = append(result, item.(map[string]interface{}))
+	}
+	return result
+}
+
+func TestDAPSession(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "dbg.frg")
+	if err := os.WriteFile(file, []byte(debugSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := newDAPClient(t)
+
+	c.request("initialize", map[string]interface{}{"adapterID": "frog"})
+	c.request("launch", map[string]interface{}{"program": file})
+	c.event("initialized")
+
+	bps := list(t, c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]interface{}{"path": file},
+		"breakpoints": []map[string]interface{}{{"line": 5}, {"line": 99}},
+	}), "breakpoints")
+	if len(bps) != 2 || bps[0]["verified"] != true || bps[0]["line"] != float64(5) || bps[1]["verified"] != false {
+		t.Fatalf("got breakpoints %v", bps)
+	}
+
+	c.request("configurationDone", nil)
+	stopped := c.event("stopped")
+	if stopped["reason"] != "breakpoint" {
+		t.Fatalf("got %v, want a stop on the breakpoint", stopped)
+	}
+
+	frames := list(t, c.request("stackTrace", map[string]interface{}{"threadId": dapThreadID}), "stackFrames")
+	if len(frames) != 2 || frames[0]["name"] != "double" || frames[0]["line"] != float64(5) ||
+		frames[1]["name"] != "FRG_Begin" || frames[1]["line"] != float64(10) {
+		t.Fatalf("got frames %v", frames)
+	}
+
+	scopes := list(t, c.request("scopes", map[string]interface{}{"frameId": 0}), "scopes")
+	if len(scopes) != 2 || scopes[0]["name"] != "Locals" || scopes[1]["name"] != "Globals" {
+		t.Fatalf("got scopes %v", scopes)
+	}
+	locals := map[string]interface{}{}
+	for _, v := range list(t, c.request("variables", map[string]interface{}{"variablesReference": scopes[0]["variablesReference"]}), "variables") {
+		locals[v["name"].(string)] = v["value"]
+	}
+	if locals["x"] != "3" || locals["y"] != "0" {
+		t.Fatalf("got locals %v", locals)
+	}
+
+	result := c.request("evaluate", map[string]interface{}{"expression": "x * 2", "frameId": 0})
+	if result["result"] != "6" || result["type"] != "INTEGER" {
+		t.Fatalf("got %v, want 6", result)
+	}
+
+	// the response to a step comes before the program stops again
+	c.request("next", map[string]interface{}{"threadId": dapThreadID})
+	if stopped := c.event("stopped"); stopped["reason"] != "step" {
+		t.Fatalf("got %v, want a step", stopped)
+	}
+	frames = list(t, c.request("stackTrace", map[string]interface{}{"threadId": dapThreadID}), "stackFrames")
+	if frames[0]["line"] != float64(6) {
+		t.Fatalf("got frames %v, want line 6", frames)
+	}
+
+	c.request("stepOut", map[string]interface{}{"threadId": dapThreadID})
+	c.event("stopped")
+	frames = list(t, c.request("stackTrace", map[string]interface{}{"threadId": dapThreadID}), "stackFrames")
+	if len(frames) != 1 || frames[0]["line"] != float64(11) {
+		t.Fatalf("got frames %v, want FRG_Begin at line 11", frames)
+	}
+
+	c.request("continue", map[string]interface{}{"threadId": dapThreadID})
+	for _, want := range []string{"6", "\n", "end\n"} {
+		if output := c.event("output"); output["category"] != "stdout" || output["output"] != want {
+			t.Fatalf("got %v, want the output %q", output, want)
+		}
+	}
+	if exited := c.event("exited"); exited["exitCode"] != float64(0) {
+		t.Fatalf("got %v, want exit code 0", exited)
+	}
+	c.event("terminated")
+
+	c.request("disconnect", nil)
+	if err := <-c.served; err != nil {
+		t.Fatalf("the server stopped: %v", err)
+	}
+}
+
+// a request that needs a stopped program fails instead of blocking
+func TestDAPNotStopped(t *testing.T) {
+	c := newDAPClient(t)
+	c.request("initialize", nil)
+	c.seq++
+	writeMessage(c.writer, map[string]interface{}{"seq": c.seq, "type": "request", "command": "next"})
+	msg := c.next()
+	if msg["success"] != false || msg["message"] != "the program is not stopped" {
+		t.Fatalf("got %v, want an error", msg)
+	}
+	c.writer.Close()
+	if err := <-c.served; err != nil {
+		t.Fatalf("the server stopped: %v", err)
+	}
+}
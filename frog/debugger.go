@@ -0,0 +1,468 @@
+// Copyright (C) by abdenour souane
+// you have a right to modify it upgrade it or do whatever you want
+// but u have to keep my name on it
+
+package frog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// the debugger hooks into Eval before every statement (see Runtime.Hook)
+// and when it has to stop it reads commands from the terminal:
+//
+//	(frog) break 12
+//	(frog) continue
+//	stopped at main.frg:12
+//	   12 |         x := x + 1#
+//	(frog) print x
+
+type debugMode int
+
+const (
+	debugContinue debugMode = iota // run up to the next breakpoint
+	debugStep                      // stop at the next statement, even inside a call
+	debugNext                      // stop at the next statement of the same call (or of a caller)
+	debugOut                       // stop at the next statement of the caller
+)
+
+// panicked by the hook to abort the program on quit
+type debugQuit struct{}
+
+type breakpoint struct {
+	id   int
+	file string
+	line int
+}
+
+type lineKey struct {
+	file string
+	line int
+}
+
+// stepper decides where a debugged program stops, frog debug and frog dap share it
+type stepper struct {
+	mode  debugMode
+	depth int // number of frames when next/out was asked
+
+	breakpoints []*breakpoint
+	nextID      int
+
+	// first statement of every line, a breakpoint stops only there
+	// so "If [x] FRG_Print x#" stops once
+	first map[lineKey]Statement
+	stmts map[string][]int // file -> lines having a statement, to move breakpoints
+
+	evaluating bool // the user is running frog code (print, evaluate), don't stop in it
+}
+
+func newStepper() stepper {
+	return stepper{
+		mode:  debugStep,
+		first: make(map[lineKey]Statement),
+		stmts: make(map[string][]int),
+	}
+}
+
+// index remembers the first statement of every line
+func (s *stepper) index(program *Program) {
+	inspect(program, func(n Node) bool {
+		stmt, ok := n.(Statement)
+		if !ok {
+			return true
+		}
+		if _, isBlock := stmt.(*BlockStatement); isBlock {
+			return true
+		}
+		tok := startToken(stmt)
+		key := lineKey{tok.File, tok.Line}
+		if _, seen := s.first[key]; !seen {
+			s.first[key] = stmt
+			s.stmts[tok.File] = append(s.stmts[tok.File], tok.Line)
+		}
+		return true
+	})
+	for _, lines := range s.stmts {
+		sort.Ints(lines)
+	}
+}
+
+// resume sets how far the program runs before stopping again
+func (s *stepper) resume(mode debugMode, env *Environment) {
+	s.mode = mode
+	s.depth = len(env.Runtime().Frames)
+}
+
+// shouldStop is asked before every statement, bp is the breakpoint hit if any
+func (s *stepper) shouldStop(stmt Statement, env *Environment) (stop bool, bp *breakpoint) {
+	if s.evaluating {
+		return false, nil
+	}
+	depth := len(env.Runtime().Frames)
+	switch s.mode {
+	case debugStep:
+		stop = true
+	case debugNext:
+		stop = depth <= s.depth
+	case debugOut:
+		stop = depth < s.depth
+	}
+
+	tok := startToken(stmt)
+	if s.first[lineKey{tok.File, tok.Line}] == stmt {
+		for _, b := range s.breakpoints {
+			if b.file == tok.File && b.line == tok.Line {
+				return true, b
+			}
+		}
+	}
+	return stop, nil
+}
+
+// addBreakpoint sets a breakpoint on the first line with a statement at or after line
+func (s *stepper) addBreakpoint(file string, line int) (*breakpoint, bool) {
+	for _, l := range s.stmts[file] {
+		if l >= line {
+			s.nextID++
+			bp := &breakpoint{id: s.nextID, file: file, line: l}
+			s.breakpoints = append(s.breakpoints, bp)
+			return bp, true
+		}
+	}
+	return nil, false
+}
+
+// eval evaluates an expression typed by the user without stopping in it
+func (s *stepper) eval(expr Expression, env *Environment) Object {
+	s.evaluating = true
+	defer func() { s.evaluating = false }()
+	return Eval(expr, env)
+}
+
+type Debugger struct {
+	stepper
+
+	file string
+	in   *bufio.Reader
+	out  io.Writer
+
+	sources map[string][]string
+	last    string // last command, repeated by an empty line
+	quit    bool
+}
+
+// NewDebugger creates a debugger for the program of file (source is its content)
+// commands are read from in, FRG_Input reads from the same input and FRG_Print
+// writes to out with the debugger
+func NewDebugger(file, source string, in io.Reader, out io.Writer) *Debugger {
+	return &Debugger{
+		stepper: newStepper(),
+		file:    file,
+		in:      bufio.NewReader(in),
+		out:     out,
+		sources: map[string][]string{file: strings.Split(source, "\n")},
+	}
+}
+
+// Run evaluates the program under the debugger, it stops before the first statement
+func (d *Debugger) Run(program *Program) (result Object) {
+	d.index(program)
+
+	env := NewEnvironment()
+	rt := env.Runtime()
+	rt.In = d.in
+	rt.Out = d.out
+	rt.Hook = d.hook
+
+	fmt.Fprintln(d.out, "frog debugger, type help for the commands")
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(debugQuit); !ok {
+				panic(r)
+			}
+			d.quit = true
+			result = nil
+		}
+	}()
+	return Eval(program, env)
+}
+
+// Quit reports if the program was stopped by the quit command
+func (d *Debugger) Quit() bool {
+	return d.quit
+}
+
+func (d *Debugger) hook(stmt Statement, env *Environment) {
+	stop, bp := d.shouldStop(stmt, env)
+	if !stop {
+		return
+	}
+
+	tok := startToken(stmt)
+	if bp != nil {
+		fmt.Fprintf(d.out, "breakpoint %d, ", bp.id)
+	}
+	fmt.Fprintf(d.out, "stopped at %s:%d\n", tok.File, tok.Line)
+	d.printLine(tok.File, tok.Line, "")
+	d.prompt(tok, env)
+}
+
+// prompt reads commands until one of them resumes the program
+func (d *Debugger) prompt(at Token, env *Environment) {
+	for {
+		fmt.Fprint(d.out, "(frog) ")
+		line, err := d.in.ReadString('\n')
+		if err != nil && line == "" {
+			// no more commands, let the program finish
+			fmt.Fprintln(d.out)
+			d.breakpoints = nil
+			d.mode = debugContinue
+			return
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			line = d.last
+		}
+		d.last = line
+
+		command, arg, _ := strings.Cut(line, " ")
+		arg = strings.TrimSpace(arg)
+		switch command {
+		case "":
+		case "s", "step":
+			d.resume(debugStep, env)
+			return
+		case "n", "next":
+			d.resume(debugNext, env)
+			return
+		case "o", "out":
+			d.resume(debugOut, env)
+			return
+		case "c", "continue":
+			d.resume(debugContinue, env)
+			return
+		case "b", "break":
+			d.setBreakpoint(arg)
+		case "d", "delete":
+			d.deleteBreakpoint(arg)
+		case "p", "print":
+			d.print(arg, env)
+		case "set":
+			d.set(arg, env)
+		case "bt", "stack":
+			d.printStack(at, env)
+		case "l", "list":
+			for line := at.Line - 3; line <= at.Line+3; line++ {
+				marker := "  "
+				if line == at.Line {
+					marker = "=>"
+				}
+				d.printLine(at.File, line, marker)
+			}
+		case "q", "quit":
+			panic(debugQuit{})
+		case "h", "help":
+			fmt.Fprint(d.out, debugHelp)
+		default:
+			fmt.Fprintf(d.out, "unknown command %q, type help for the commands\n", command)
+		}
+	}
+}
+
+const debugHelp = `commands:
+  break [file:]line  stop when that line is reached, without argument list the breakpoints
+  delete id          remove a breakpoint
+  step               run the next statement, going into FRG_Fn calls
+  next               run the next statement, stepping over FRG_Fn calls
+  out                run until the current FRG_Fn returns
+  continue           run until the next breakpoint
+  print [expr]       print an expression, without argument print every variable
+  set x := expr      change a variable (or an array element)
+  stack              show the FRG_Fn calls in progress
+  list               show the source around the current line
+  quit               stop the program
+an empty line repeats the last command
+`
+
+func (d *Debugger) setBreakpoint(arg string) {
+	if arg == "" {
+		if len(d.breakpoints) == 0 {
+			fmt.Fprintln(d.out, "no breakpoints")
+		}
+		for _, bp := range d.breakpoints {
+			fmt.Fprintf(d.out, "%d: %s:%d\n", bp.id, bp.file, bp.line)
+		}
+		return
+	}
+
+	file := d.file
+	lineText := arg
+	if i := strings.LastIndex(arg, ":"); i >= 0 {
+		file = d.findFile(arg[:i])
+		lineText = arg[i+1:]
+		if file == "" {
+			fmt.Fprintf(d.out, "no statement comes from %s\n", arg[:i])
+			return
+		}
+	}
+	line, err := strconv.Atoi(lineText)
+	if err != nil || line < 1 {
+		fmt.Fprintf(d.out, "bad line number %q\n", lineText)
+		return
+	}
+
+	bp, ok := d.addBreakpoint(file, line)
+	if !ok {
+		fmt.Fprintf(d.out, "no statement at or after %s:%d\n", file, line)
+		return
+	}
+	fmt.Fprintf(d.out, "breakpoint %d at %s:%d\n", bp.id, bp.file, bp.line)
+}
+
+// findFile matches a file name typed by the user with the files of the program
+func (d *Debugger) findFile(name string) string {
+	if _, ok := d.stmts[name]; ok {
+		return name
+	}
+	for file := range d.stmts {
+		if filepath.Base(file) == name || filepath.Clean(file) == filepath.Clean(name) {
+			return file
+		}
+	}
+	return ""
+}
+
+func (d *Debugger) deleteBreakpoint(arg string) {
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		fmt.Fprintf(d.out, "usage: delete <breakpoint id>\n")
+		return
+	}
+	for i, bp := range d.breakpoints {
+		if bp.id == id {
+			d.breakpoints = append(d.breakpoints[:i], d.breakpoints[i+1:]...)
+			fmt.Fprintf(d.out, "deleted breakpoint %d\n", id)
+			return
+		}
+	}
+	fmt.Fprintf(d.out, "no breakpoint %d\n", id)
+}
+
+func (d *Debugger) print(arg string, env *Environment) {
+	if arg == "" {
+		names := make([]string, 0, len(env.store)+len(env.statics))
+		for name, val := range env.store {
+			if _, isFn := val.(*Function); !isFn {
+				names = append(names, name)
+			}
+		}
+		for name := range env.statics {
+			if _, ok := env.store[name]; !ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			val, _ := env.Get(name)
+			fmt.Fprintf(d.out, "%s = %s\n", name, inspectValue(val))
+		}
+		return
+	}
+
+	expr, err := parseDebugExpression(arg)
+	if err != nil {
+		fmt.Fprintln(d.out, err)
+		return
+	}
+	fmt.Fprintln(d.out, inspectValue(d.eval(expr, env)))
+}
+
+func (d *Debugger) set(arg string, env *Environment) {
+	left, right, ok := strings.Cut(arg, ":=")
+	if !ok {
+		left, right, ok = strings.Cut(arg, "=")
+	}
+	if !ok {
+		fmt.Fprintln(d.out, "usage: set x := expr")
+		return
+	}
+	target, err := parseDebugExpression(left)
+	if err != nil {
+		fmt.Fprintln(d.out, err)
+		return
+	}
+	value, err := parseDebugExpression(right)
+	if err != nil {
+		fmt.Fprintln(d.out, err)
+		return
+	}
+
+	val := d.eval(value, env)
+	if isError(val) {
+		fmt.Fprintln(d.out, val.Inspect())
+		return
+	}
+	d.evaluating = true
+	result := evalAssignmentToExpression(target, val, env)
+	d.evaluating = false
+	if isError(result) {
+		fmt.Fprintln(d.out, result.Inspect())
+		return
+	}
+	fmt.Fprintf(d.out, "%s = %s\n", target.String(), inspectValue(val))
+}
+
+func parseDebugExpression(text string) (Expression, error) {
+	p := NewParser(NewLexer(strings.TrimSpace(text)))
+	expr := p.parseExpression(LOWEST)
+	if p.IsThereAnyErrors() {
+		return nil, fmt.Errorf("%s", p.Diagnostics()[0].Message)
+	}
+	if !p.peekTokenIs(TokenEOF) {
+		return nil, fmt.Errorf("unexpected %q after the expression", p.peekToken.Literal)
+	}
+	return expr, nil
+}
+
+func inspectValue(val Object) string {
+	if val == nil {
+		return "(no value)"
+	}
+	return val.Inspect()
+}
+
+func (d *Debugger) printStack(at Token, env *Environment) {
+	frames := env.Runtime().Frames
+	pos := at
+	for i := len(frames) - 1; i >= 0; i-- {
+		frame := frames[i]
+		args := []string{}
+		for _, param := range frame.Function.Parameters {
+			args = append(args, param.Name.Value+"="+inspectValue(frame.Env.store[param.Name.Value]))
+		}
+		fmt.Fprintf(d.out, "#%d %s(%s) at %s:%d\n", len(frames)-1-i, frame.Function.Name, strings.Join(args, ", "), pos.File, pos.Line)
+		pos = startToken(frame.Call)
+	}
+	fmt.Fprintf(d.out, "#%d FRG_Begin at %s:%d\n", len(frames), pos.File, pos.Line)
+}
+
+func (d *Debugger) printLine(file string, line int, marker string) {
+	lines, ok := d.sources[file]
+	if !ok {
+		// statements of a FRG_Use file
+		if content, err := ReadSource(file); err == nil {
+			lines = strings.Split(string(content), "\n")
+		}
+		d.sources[file] = lines
+	}
+	if line < 1 || line > len(lines) {
+		return
+	}
+	fmt.Fprintf(d.out, "%s%5d | %s\n", marker, line, strings.TrimRight(lines[line-1], "\r"))
+}
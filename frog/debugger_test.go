@@ -0,0 +1,103 @@
+// Copyright (C) by abdenour souane
+// you have a right to modify it upgrade it or do whatever you want
+// but u have to keep my name on it
+
+package frog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const debugSource = `FRG_Begin
+    FRG_Fn double(FRG_Int x) : FRG_Int
+    Begin
+        FRG_Int y#
+        y := x * 2#
+        double := y#
+    End
+    FRG_Int a#
+    a := 3#
+    a := double(a)#
+    FRG_Print a, "\n"#
+    FRG_Print "end\n"#
+FRG_End
+`
+
+// debugSession runs debugSource under the debugger with the commands, one per line
+func debugSession(t *testing.T, commands ...string) (*Debugger, Object, string) {
+	t.Helper()
+	program := parseSource(t, "dbg.frg", debugSource)
+	var out bytes.Buffer
+	d := NewDebugger("dbg.frg", debugSource, strings.NewReader(strings.Join(commands, "\n")+"\n"), &out)
+	result := d.Run(program)
+	return d, result, out.String()
+}
+
+func TestDebugger(t *testing.T) {
+	d, result, out := debugSession(t,
+		"break 5", "break", "continue", "print x", "stack",
+		"step", "print y", "set y := 100", "out", "print a",
+		"next", "print", "delete 1", "break 99", "list", "continue")
+	if result != nil || d.Quit() {
+		t.Fatalf("got %v, want the program to finish", result)
+	}
+	want := `frog debugger, type help for the commands
+stopped at dbg.frg:2
+    2 |     FRG_Fn double(FRG_Int x) : FRG_Int
+(frog) breakpoint 1 at dbg.frg:5
+(frog) 1: dbg.frg:5
+(frog) breakpoint 1, stopped at dbg.frg:5
+    5 |         y := x * 2#
+(frog) 3
+(frog) #0 double(x=3) at dbg.frg:5
+#1 FRG_Begin at dbg.frg:10
+(frog) stopped at dbg.frg:6
+    6 |         double := y#
+(frog) 6
+(frog) y = 100
+(frog) stopped at dbg.frg:11
+   11 |     FRG_Print a, "\n"#
+(frog) 100
+(frog) 100
+stopped at dbg.frg:12
+   12 |     FRG_Print "end\n"#
+(frog) a = 100
+(frog) deleted breakpoint 1
+(frog) no statement at or after dbg.frg:99
+(frog)       9 |     a := 3#
+     10 |     a := double(a)#
+     11 |     FRG_Print a, "\n"#
+=>   12 |     FRG_Print "end\n"#
+     13 | FRG_End
+     14 | 
+(frog) end
+`
+	if out != want {
+		t.Errorf("got\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestDebuggerQuit(t *testing.T) {
+	d, result, out := debugSession(t, "next", "quit")
+	if result != nil || !d.Quit() {
+		t.Errorf("got %v, want the program stopped by quit", result)
+	}
+	if strings.Contains(out, "end") {
+		t.Errorf("the program kept running after quit:\n%s", out)
+	}
+}
+
+// without commands left the program runs to its end
+func TestDebuggerEndOfInput(t *testing.T) {
+	_, result, out := debugSession(t, "print nothing_here", "set a")
+	if result != nil {
+		t.Fatalf("got %v", result)
+	}
+	for _, want := range []string{"identifier not found: nothing_here", "usage: set x := expr", "\n6\nend\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright (C) by abdenour souane
+// you have a right to modify it upgrade it or do whatever you want
+// but u have to keep my name on it
+
+package frog
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return "unknown"
+	}
+}
+
+func (s Severity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// Diagnostic is a problem found in a frog source (parser error, lint warning, ...)
+// lines and columns start at 1, the span ends before EndLine:EndColumn
+type Diagnostic struct {
+	Severity  Severity `json:"severity"`
+	File      string   `json:"file"`
+	Line      int      `json:"line"`
+	Column    int      `json:"column"`
+	EndLine   int      `json:"endLine"`
+	EndColumn int      `json:"endColumn"`
+	Code      string   `json:"code"` // short id, e.g. expected-token or a lint rule
+	Message   string   `json:"message"`
+}
+
+// tokenEnd returns the column just after the token
+func tokenEnd(tok Token) int {
+	length := utf8.RuneCountInString(tok.Literal)
+	if tok.Type == TokenString {
+		length += 2 // the quotes are not part of the literal
+	}
+	if length == 0 {
+		length = 1
+	}
+	return tok.Column + length
+}
+
+// newDiagnostic builds a diagnostic spanning the token
+func newDiagnostic(severity Severity, tok Token, code, format string, a ...interface{}) Diagnostic {
+	return Diagnostic{
+		Severity:  severity,
+		File:      tok.File,
+		Line:      tok.Line,
+		Column:    tok.Column,
+		EndLine:   tok.Line,
+		EndColumn: tokenEnd(tok),
+		Code:      code,
+		Message:   fmt.Sprintf(format, a...),
+	}
+}
+
+// String returns the one line form: file:line:col: error[code]: message
+func (d Diagnostic) String() string {
+	var out strings.Builder
+	if d.File != "" {
+		out.WriteString(d.File + ":")
+	}
+	fmt.Fprintf(&out, "%d:%d: %s", d.Line, d.Column, d.Severity)
+	if d.Code != "" {
+		out.WriteString("[" + d.Code + "]")
+	}
+	out.WriteString(": " + d.Message)
+	return out.String()
+}
+
+// Render returns the diagnostic followed by the offending source line
+// and a caret underline of the span:
+//
+//	main.frg:3:10: error[expected-expression]: expected an expression, got HASH
+//	    3 |     x := #
+//	      |          ^
+func (d Diagnostic) Render(source string) string {
+	var out strings.Builder
+	out.WriteString(d.String() + "\n")
+
+	lines := strings.Split(source, "\n")
+	if d.Line < 1 || d.Line > len(lines) {
+		return out.String()
+	}
+	text := strings.TrimRight(lines[d.Line-1], "\r")
+	gutter := fmt.Sprintf("%5d | ", d.Line)
+	out.WriteString(gutter + text + "\n")
+
+	// keep the tabs of the source so the caret lines up
+	runes := []rune(text)
+	pad := make([]rune, 0, d.Column)
+	for i := 0; i < d.Column-1; i++ {
+		if i < len(runes) && runes[i] == '\t' {
+			pad = append(pad, '\t')
+		} else {
+			pad = append(pad, ' ')
+		}
+	}
+	width := d.EndColumn - d.Column
+	if d.EndLine != d.Line {
+		width = len(runes) - d.Column + 1
+	}
+	if width < 1 {
+		width = 1
+	}
+	out.WriteString(strings.Repeat(" ", len(gutter)-2) + "| " + string(pad) + strings.Repeat("^", width) + "\n")
+	return out.String()
+}
@@ -0,0 +1,48 @@
+// Copyright (C) by abdenour souane
+// you have a right to modify it upgrade it or do whatever you want
+// but u have to keep my name on it
+
+package frog
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const brokenSource = "FRG_Begin\n    FRG_Print #\nFRG_End\n"
+
+func parseDiagnostics(code string) []Diagnostic {
+	parser := NewParser(NewFileLexer("broken.frg", code))
+	parser.ParseProgram()
+	return parser.Diagnostics()
+}
+
+// the shape -json prints, editors read these names
+func TestDiagnosticJSON(t *testing.T) {
+	out, err := json.Marshal(parseDiagnostics(brokenSource))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"severity":"error","file":"broken.frg","line":2,"column":15,"endLine":2,"endColumn":16,` +
+		`"code":"expected-expression","message":"expected an expression, got HASH"}]`
+	if string(out) != want {
+		t.Errorf("got\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestDiagnosticRender(t *testing.T) {
+	diagnostics := parseDiagnostics(brokenSource)
+	if len(diagnostics) != 1 {
+		t.Fatalf("got %v, want one diagnostic", diagnostics)
+	}
+	want := "broken.frg:2:15: error[expected-expression]: expected an expression, got HASH\n" +
+		"    2 |     FRG_Print #\n" +
+		"      |               ^\n"
+	if got := diagnostics[0].Render(brokenSource); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	// without the source only the first line is left
+	if got := diagnostics[0].Render(""); got != "broken.frg:2:15: error[expected-expression]: expected an expression, got HASH\n" {
+		t.Errorf("without the source got %q", got)
+	}
+}
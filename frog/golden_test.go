@@ -0,0 +1,157 @@
+// Copyright (C) by abdenour souane
+// you have a right to modify it upgrade it or do whatever you want
+// but u have to keep my name on it
+
+package frog
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// the golden tests run every tests/x.frg having a x.frg.expected file and compare
+// what it prints with it, like `frog x.frg` would:
+//
+//	x.frg.stdin     is fed to FRG_Input (nothing is read otherwise)
+//	x.frg.error     the program must stop with this error (message and traceback),
+//	                x.frg.expected then holds only what was printed before it
+//
+// go test ./frog -run Golden -update rewrites the files with the current output
+
+var update = flag.Bool("update", false, "rewrite the .expected and .error files of tests/ with the current output")
+
+const goldenDir = "../tests"
+
+func TestGolden(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join(goldenDir, "*.frg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := 0
+	for _, file := range files {
+		name := filepath.Base(file)
+		if !exists(file+".expected") && !exists(file+".error") {
+			continue
+		}
+		found++
+		t.Run(strings.TrimSuffix(name, ".frg"), func(t *testing.T) {
+			// the fixtures are read by their base name, so the file names in
+			// the diagnostics and tracebacks do not depend on where the repo is
+			t.Chdir(goldenDir)
+			runGoldenTest(t, name)
+		})
+	}
+	if found == 0 {
+		t.Fatalf("no golden tests found in %s", goldenDir)
+	}
+}
+
+func runGoldenTest(t *testing.T, name string) {
+	stdin, _ := os.ReadFile(name + ".stdin")
+	output, errText := runGolden(t, name, stdin)
+
+	expectError := exists(name + ".error")
+	if !expectError {
+		output += errText
+	}
+
+	if *update {
+		if expectError {
+			writeGolden(t, name+".error", errText)
+			if output == "" && !exists(name+".expected") {
+				return
+			}
+		}
+		writeGolden(t, name+".expected", output)
+		return
+	}
+
+	if expectError {
+		if errText == "" {
+			t.Errorf("%s: expected an error, the program finished", name)
+		} else {
+			compareGolden(t, name+".error", errText)
+		}
+		if !exists(name + ".expected") {
+			return
+		}
+	}
+	compareGolden(t, name+".expected", output)
+}
+
+// runGolden runs the file the way main does, parse errors are rendered
+// in the output, a runtime error is returned apart
+func runGolden(t *testing.T, name string, stdin []byte) (output, errText string) {
+	code, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+
+	parser := NewParser(NewFileLexer(name, string(code)))
+	program := parser.ParseProgram()
+	if parser.IsThereAnyErrors() {
+		sources := map[string]string{name: string(code)}
+		for _, d := range parser.Diagnostics() {
+			source, ok := sources[d.File]
+			if !ok {
+				if content, err := ReadSource(d.File); err == nil {
+					source = string(content)
+				}
+				sources[d.File] = source
+			}
+			out.WriteString(d.Render(source))
+		}
+		return out.String(), ""
+	}
+
+	env := NewEnvironment()
+	env.Runtime().Out = &out
+	env.Runtime().In = bufio.NewReader(bytes.NewReader(stdin))
+
+	var evaluated Object
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				t.Fatalf("%s: the interpreter panicked: %v", name, r)
+			}
+		}()
+		evaluated = Eval(program, env)
+	}()
+
+	if rerr, ok := evaluated.(*Error); ok {
+		return out.String(), rerr.Inspect() + "\n" + rerr.Traceback()
+	}
+	if evaluated != nil {
+		fmt.Fprintln(&out, evaluated.Inspect())
+	}
+	return out.String(), ""
+}
+
+// compareGolden ignores the newlines at the end like the shell $(...) of run_tests.sh
+func compareGolden(t *testing.T, file, got string) {
+	want, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimRight(got, "\n") != strings.TrimRight(string(want), "\n") {
+		t.Errorf("%s differs (go test -run Golden -update rewrites it)\n--- want\n%s\n--- got\n%s", file, want, got)
+	}
+}
+
+func writeGolden(t *testing.T, file, content string) {
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"bytes"
 	"fmt"
-	"io"
 	"os"
 	"strings"
 
@@ -22,6 +21,22 @@ const buttonPadding = 10
 const editorHeight = 400
 const editorWidth = 900
 
+// runProgram evaluates the program and returns what it printed
+// a crash of the interpreter is reported as an error instead of closing the window
+func runProgram(program *frog.Program) (output string, evaluated frog.Object) {
+	var buf bytes.Buffer
+	env := frog.NewEnvironment()
+	env.Runtime().Out = &buf
+	defer func() {
+		if r := recover(); r != nil {
+			output = buf.String()
+			evaluated = &frog.Error{Message: fmt.Sprintf("interpreter crashed: %v", r)}
+		}
+	}()
+	evaluated = frog.Eval(program, env)
+	return buf.String(), evaluated
+}
+
 func main() {
 	rl.SetConfigFlags(rl.FlagMsaa4xHint)
 	rl.InitWindow(screenWidth, screenHeight, "Frog Language Raylib GUI")
@@ -114,23 +129,11 @@ func main() {
 						contentText = errs.String()
 						editorLines = strings.Split(contentText, "\n")
 					} else {
-						oldStdout := os.Stdout
-						r, wFile, _ := os.Pipe()
-						os.Stdout = wFile
-
-						env := frog.NewEnvironment()
-						evaluated := frog.Eval(program, env)
-
-						wFile.Close()
-						os.Stdout = oldStdout
-
-						var buf bytes.Buffer
-						io.Copy(&buf, r)
-
-						if evaluated != nil && evaluated.Type() == "ERROR" {
-							contentText = evaluated.Inspect()
+						output, evaluated := runProgram(program)
+						if rerr, ok := evaluated.(*frog.Error); ok {
+							contentText = output + "\n" + rerr.Inspect() + "\n" + rerr.Traceback()
 						} else {
-							contentText = buf.String()
+							contentText = output
 						}
 						editorLines = strings.Split(contentText, "\n")
 					}
@@ -14,11 +14,20 @@ func isError(obj Object) bool {
 	return false
 }
 
-func newError(line, col int, format string, a ...interface{}) *Error {
+// newLimitError creates an error of one of the limits of the runtime
+func newLimitError(kind ErrorKind, tok Token, format string, a ...interface{}) *Error {
+	err := newError(tok, format, a...)
+	err.Kind = kind
+	return err
+}
+
+// newError creates a runtime error at the position of tok
+func newError(tok Token, format string, a ...interface{}) *Error {
 	return &Error{
 		Message: fmt.Sprintf(format, a...),
-		Line:    line,
-		Col:     col,
+		Line:    tok.Line,
+		Col:     tok.Column,
+		File:    tok.File,
 	}
 }
 
@@ -91,6 +100,10 @@ func TokenToString(tokenType TokenType) string {
 		return "RBRACKET"
 	case TokenHash:
 		return "HASH"
+	case TokenDot:
+		return "DOT"
+	case TokenEllipsis:
+		return "ELLIPSIS"
 	case TokenFRGBegin:
 		return "FRG_BEGIN"
 	case TokenFRGEnd:
@@ -129,6 +142,24 @@ func TokenToString(tokenType TokenType) string {
 		return "FALSE"
 	case TokenFRGUse:
 		return "FRG_USE"
+	case TokenAssert:
+		return "ASSERT"
+	case TokenFRGExport:
+		return "FRG_EXPORT"
+	case TokenAs:
+		return "AS"
+	case TokenFrom:
+		return "FROM"
+	case TokenFRGStatic:
+		return "FRG_STATIC"
+	case TokenFRGVoid:
+		return "FRG_VOID"
+	case TokenFRGConst:
+		return "FRG_CONST"
+	case TokenFRGEnum:
+		return "FRG_ENUM"
+	case TokenComment:
+		return "COMMENT"
 	default:
 		return "UNKNOWN"
 	}
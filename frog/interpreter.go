@@ -4,38 +4,105 @@
 package frog
 
 import (
-	"bufio"
 	"fmt"
 	"math"
-	"os"
 	"strconv"
+	"strings"
 )
 
 type Environment struct {
-	store map[string]Object
+	store   map[string]Object
+	runtime *Runtime // shared with the environments of the calls
+
+	// the FRG_Static variables of the function being called, they
+	// outlive the call so they are kept apart from store
+	statics map[string]Object
+
+	// the names bound by FRG_Const and FRG_Enum with the identifier that
+	// declared them, nil for the ones a call gets from the file around it
+	consts map[string]*Identifier
 }
 
-// constructor
-func NewEnvironment() *Environment {
+// constructor, the options configure the run (see WithMaxCallDepth)
+func NewEnvironment(opts ...Option) *Environment {
 	s := make(map[string]Object)
-	return &Environment{store: s}
+	return &Environment{store: s, runtime: NewRuntime(opts...)}
+}
+
+// Runtime returns the state shared by the whole run (hook, call stack, input)
+func (e *Environment) Runtime() *Runtime {
+	return e.runtime
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
+	if obj, ok := e.statics[name]; ok {
+		return obj, ok
+	}
 	obj, ok := e.store[name]
 	return obj, ok
 }
 
 func (e *Environment) Set(name string, val Object) Object {
+	if _, ok := e.statics[name]; ok {
+		e.statics[name] = val
+		return val
+	}
 	e.store[name] = val
 	return val
 }
 
+// constant reports a name bound by FRG_Const
+func (e *Environment) constant(name string) bool {
+	_, ok := e.consts[name]
+	return ok
+}
+
+func (e *Environment) setConstant(ident *Identifier, val Object) {
+	if e.consts == nil {
+		e.consts = make(map[string]*Identifier)
+	}
+	e.consts[ident.Value] = ident
+	e.store[ident.Value] = val
+}
+
+// declare lets ident name something new in e. a constant of the file is
+// hidden by a local of a function, one bound in e itself cannot be declared
+// again, except by its own declaration running again in a Repeat
+func (e *Environment) declare(ident *Identifier) *Error {
+	decl, ok := e.consts[ident.Value]
+	if !ok || decl == ident {
+		return nil
+	}
+	if decl != nil {
+		return newError(ident.Token, "cannot declare %s again, it is a %s", ident.Value, constKeyword(e, ident.Value))
+	}
+	delete(e.consts, ident.Value)
+	return nil
+}
+
 func Eval(node Node, env *Environment) Object {
+	if stmt, ok := node.(Statement); ok {
+		switch stmt.(type) {
+		case *BlockStatement, *ExportStatement:
+			// their statements are counted, not them
+		default:
+			if err := env.runtime.beforeStatement(stmt, env); err != nil {
+				return err
+			}
+		}
+	}
+
 	switch node := node.(type) {
 	case *Program:
 		return evalProgram(node, env)
 	case *ExpressionStatement:
+		if call, ok := node.Expression.(*CallExpression); ok {
+			// the only place a FRG_Void call is allowed, there is no value to use
+			if result := evalCall(call, env); result != VOID {
+				return result
+			}
+			return nil
+		}
 		return Eval(node.Expression, env)
 	case *IntegerLiteral:
 		return &Int{Value: node.Value}
@@ -45,10 +112,19 @@ func Eval(node Node, env *Environment) Object {
 		return &String{Value: node.Value}
 	case *PrefixExpression:
 		right := Eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
 		return evalPrefixExpression(node, right)
 	case *InfixExpression:
 		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
 		right := Eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
 		return evalInfixExpression(node, left, right)
 	case *ArrayLiteral:
 		return evalArrayLiteral(node, env)
@@ -70,6 +146,8 @@ func Eval(node Node, env *Environment) Object {
 		return evalBlockStatement(node, env)
 	case *PrintStatement:
 		return evalPrintStatement(node, env)
+	case *AssertStatement:
+		return evalAssertStatement(node, env)
 	case *InputStatement:
 		return evalInputStatement(node, env)
 	case *Boolean:
@@ -84,14 +162,35 @@ func Eval(node Node, env *Environment) Object {
 		return CONTINUE
 	case *FunctionDeclarationStatement:
 		return evalFunctionDeclarationStatement(node, env)
+	case *UseStatement:
+		return evalUseStatement(node, env)
+	case *ExportStatement:
+		return Eval(node.Statement, env)
+	case *StaticStatement:
+		return evalStaticStatement(node, env)
+	case *ConstStatement:
+		return evalConstStatement(node, env)
+	case *EnumStatement:
+		return evalEnumStatement(node, env)
+	case *MemberExpression:
+		return evalMemberExpression(node, env)
 	case *CallExpression:
 		return evalCallExpression(node, env)
+	case *FunctionLiteral:
+		return evalFunctionLiteral(node, env)
 	}
 	return nil
 }
 
 func evalRepeatStatement(rs *RepeatStatement, env *Environment) Object {
 	for {
+		// a Repeat ... Until [False] must not run forever in a grader
+		if err := env.runtime.checkContext(rs.Token); err != nil {
+			return err
+		}
+		if err := env.runtime.step(rs.Token); err != nil {
+			return err
+		}
 		for _, statement := range rs.Body {
 			result := Eval(statement, env)
 			if isError(result) {
@@ -120,15 +219,18 @@ func evalIfStatement(is *IfStatement, env *Environment) Object {
 	if isError(condition) {
 		return condition
 	}
+	if env.runtime.Coverage != nil {
+		env.runtime.Coverage.branch(is, isTruthy(condition))
+	}
 	if isTruthy(condition) {
 		result := Eval(is.Consequence, env)
-		if result != nil && (result.Type() == BREAK_OBJ || result.Type() == CONTINUE_OBJ) {
+		if result != nil && (result.Type() == BREAK_OBJ || result.Type() == CONTINUE_OBJ || isError(result)) {
 			return result
 		}
 		return nil
 	} else if is.Alternative != nil {
 		result := Eval(is.Alternative, env)
-		if result != nil && (result.Type() == BREAK_OBJ || result.Type() == CONTINUE_OBJ) {
+		if result != nil && (result.Type() == BREAK_OBJ || result.Type() == CONTINUE_OBJ || isError(result)) {
 			return result
 		}
 		return nil
@@ -151,10 +253,111 @@ func evalBlockStatement(block *BlockStatement, env *Environment) Object {
 	return result
 }
 
+func evalUseStatement(node *UseStatement, env *Environment) Object {
+	if env.runtime.Sandbox {
+		return newLimitError(ErrorSandbox, node.Token, "FRG_Use is not allowed in the sandbox")
+	}
+	module, err := loadModule(node, env)
+	if err != nil {
+		return err
+	}
+	if node.Alias != nil {
+		env.Set(node.Alias.Value, module)
+		return nil
+	}
+	for _, name := range node.Names {
+		if !module.Exports[name.Value] {
+			return newError(name.Token, "%s is not exported by module %s", name.Value, module.Name)
+		}
+		val, _ := module.Env.Get(name.Value)
+		if err := env.declare(name); err != nil {
+			return err
+		}
+		if module.Env.constant(name.Value) {
+			env.setConstant(name, val)
+		} else {
+			env.Set(name.Value, val)
+		}
+	}
+	return nil
+}
+
+// loadModule runs the file of a FRG_Use the first time it is used
+func loadModule(node *UseStatement, env *Environment) (*Module, *Error) {
+	rt := env.runtime
+	if module, ok := rt.modules[node.Path]; ok {
+		return module, nil
+	}
+	for i, use := range rt.loading {
+		if use.Path != node.Path {
+			continue
+		}
+		chain := []string{}
+		for _, use := range rt.loading[i:] {
+			chain = append(chain, use.Filename.Value)
+		}
+		chain = append(chain, node.Filename.Value)
+		return nil, newError(node.Token, "import cycle: %s", strings.Join(chain, " -> "))
+	}
+	rt.loading = append(rt.loading, node)
+	defer func() { rt.loading = rt.loading[:len(rt.loading)-1] }()
+
+	module := &Module{
+		Name:    moduleName(node.Filename.Value),
+		Path:    node.Path,
+		Env:     &Environment{store: make(map[string]Object), runtime: rt},
+		Exports: make(map[string]bool),
+	}
+	for _, stmt := range node.Module.Statements {
+		if export, ok := stmt.(*ExportStatement); ok {
+			for _, name := range export.Names() {
+				module.Exports[name.Value] = true
+			}
+		}
+	}
+	if result := evalProgram(node.Module, module.Env); isError(result) {
+		return nil, result.(*Error)
+	}
+	if rt.modules == nil {
+		rt.modules = make(map[string]*Module)
+	}
+	rt.modules[node.Path] = module
+	return module, nil
+}
+
+func evalMemberExpression(node *MemberExpression, env *Environment) Object {
+	object := Eval(node.Object, env)
+	if isError(object) {
+		return object
+	}
+	if enum, ok := object.(*Enum); ok {
+		if member := enum.member(node.Member.Value); member != nil {
+			return member
+		}
+		return newError(node.Member.Token, "FRG_Enum %s has no member %s", enum.Name, node.Member.Value)
+	}
+	module, ok := object.(*Module)
+	if !ok {
+		return newError(node.Token, "%s is not a module or a FRG_Enum, it has no member %s", node.Object.String(), node.Member.Value)
+	}
+	val, ok := module.Env.Get(node.Member.Value)
+	if !ok {
+		return newError(node.Member.Token, "module %s has no member %s", module.Name, node.Member.Value)
+	}
+	if !module.Exports[node.Member.Value] {
+		return newError(node.Member.Token, "%s is not exported by module %s", node.Member.Value, module.Name)
+	}
+	return val
+}
+
 func evalProgram(program *Program, env *Environment) Object {
 	var result Object
 	for _, statement := range program.Statements {
 		result = Eval(statement, env)
+		if isError(result) {
+			// the program stops at the first error
+			return result
+		}
 	}
 	return result
 }
@@ -164,7 +367,7 @@ func evalPrefixExpression(node *PrefixExpression, right Object) Object {
 	case "-":
 		return evalMinusPrefixOperatorExpression(node, right)
 	default:
-		return newError(node.Token.Line, node.Token.Column, "unknown operator: %s%s", node.Operator, right.Type())
+		return newError(node.Token, "unknown operator: %s%s", node.Operator, right.Type())
 	}
 }
 
@@ -177,10 +380,14 @@ func evalMinusPrefixOperatorExpression(node *PrefixExpression, right Object) Obj
 		value := right.(*Real).Value
 		return &Real{Value: -value}
 	}
-	return newError(node.Token.Line, node.Token.Column, "unknown operator: -%s", right.Type())
+	return newError(node.Token, "unknown operator: -%s", right.Type())
 }
 
 func evalInfixExpression(node *InfixExpression, left, right Object) Object {
+	left, right, err := enumOperands(node, left, right)
+	if err != nil {
+		return err
+	}
 	switch {
 	case left.Type() == INTEGER_OBJ && right.Type() == INTEGER_OBJ:
 		return evalIntegerInfixExpression(node, left, right)
@@ -189,9 +396,9 @@ func evalInfixExpression(node *InfixExpression, left, right Object) Object {
 	case left.Type() == STRING_OBJ && right.Type() == STRING_OBJ:
 		return evalStringInfixExpression(node, left, right)
 	case left.Type() != right.Type():
-		return newError(node.Token.Line, node.Token.Column, "type mismatch: %s %s %s", left.Type(), node.Operator, right.Type())
+		return newError(node.Token, "type mismatch: %s %s %s", left.Type(), node.Operator, right.Type())
 	default:
-		return newError(node.Token.Line, node.Token.Column, "unknown operator: %s %s %s", left.Type(), node.Operator, right.Type())
+		return newError(node.Token, "unknown operator: %s %s %s", left.Type(), node.Operator, right.Type())
 	}
 }
 
@@ -207,13 +414,13 @@ func evalIntegerInfixExpression(node *InfixExpression, left, right Object) Objec
 		return &Int{Value: leftVal * rightVal}
 	case "/":
 		if rightVal == 0 {
-			return newError(node.Token.Line, node.Token.Column, "ERROR: u can't divis per zero")
+			return newError(node.Token, "ERROR: u can't divis per zero")
 		} else {
 			return &Real{Value: float64(leftVal) / float64(rightVal)}
 		}
 	case "%":
 		if rightVal == 0 {
-			return newError(node.Token.Line, node.Token.Column, "ERROR: u can't divis per zero")
+			return newError(node.Token, "ERROR: u can't divis per zero")
 		} else {
 			return &Int{Value: leftVal % rightVal}
 		}
@@ -230,7 +437,7 @@ func evalIntegerInfixExpression(node *InfixExpression, left, right Object) Objec
 	case "!=":
 		return nativeBoolToBooleanObject(leftVal != rightVal)
 	default:
-		return newError(node.Token.Line, node.Token.Column, "unknown operator: %s %s %s", left.Type(), node.Operator, right.Type())
+		return newError(node.Token, "unknown operator: %s %s %s", left.Type(), node.Operator, right.Type())
 	}
 }
 
@@ -246,13 +453,13 @@ func evalRealInfixExpression(node *InfixExpression, left, right Object) Object {
 		return &Real{Value: leftVal * rightVal}
 	case "/":
 		if rightVal == 0 {
-			return newError(node.Token.Line, node.Token.Column, "ERROR: u can't divis per zero")
+			return newError(node.Token, "ERROR: u can't divis per zero")
 		} else {
 			return &Real{Value: leftVal / rightVal}
 		}
 	case "%":
 		if rightVal == 0 {
-			return newError(node.Token.Line, node.Token.Column, "ERROR: u can't divis per zero")
+			return newError(node.Token, "ERROR: u can't divis per zero")
 		} else {
 			return &Real{Value: math.Mod(leftVal, rightVal)}
 		}
@@ -269,7 +476,7 @@ func evalRealInfixExpression(node *InfixExpression, left, right Object) Object {
 	case "!=":
 		return nativeBoolToBooleanObject(leftVal != rightVal)
 	default:
-		return newError(node.Token.Line, node.Token.Column, "unknown operator: %s %s %s", left.Type(), node.Operator, right.Type())
+		return newError(node.Token, "unknown operator: %s %s %s", left.Type(), node.Operator, right.Type())
 	}
 }
 
@@ -277,29 +484,192 @@ func evalStringInfixExpression(node *InfixExpression, left, right Object) Object
 	leftVal := left.(*String).Value
 	rightVal := right.(*String).Value
 	if node.Operator != "+" {
-		return newError(node.Token.Line, node.Token.Column, "unknown operator: %s %s %s", left.Type(), node.Operator, right.Type())
+		return newError(node.Token, "unknown operator: %s %s %s", left.Type(), node.Operator, right.Type())
 	}
 	return &String{Value: leftVal + rightVal}
 }
 
 func evalIdentifier(node *Identifier, env *Environment) Object {
 	if val, ok := env.Get(node.Value); ok {
+		if val == nil {
+			return newError(node.Token, "%s is read before it is assigned", node.Value)
+		}
 		return val
 	}
-	return newError(node.Token.Line, node.Token.Column, "identifier not found: "+node.Value)
+	if builtin, ok := builtins[node.Value]; ok {
+		return builtin
+	}
+	return newError(node.Token, "identifier not found: %s", node.Value)
 }
 
 func evalDeclarationStatement(node *DeclarationStatement, env *Environment) Object {
-	for _, ident := range node.Identifiers {
-		if node.IsArray {
-			env.Set(ident.Value, &Array{Elements: []Object{}})
-		} else {
-			env.Set(ident.Value, nil)
+	for i, ident := range node.Identifiers {
+		if err := env.declare(ident); err != nil {
+			return err
+		}
+		val := initialValue(node, i, env)
+		if isError(val) {
+			return val
+		}
+		env.Set(ident.Value, val)
+	}
+	return nil
+}
+
+// initialValue gives the value a declared variable starts with, its
+// initializer or the zero value of its type
+func initialValue(node *DeclarationStatement, i int, env *Environment) Object {
+	value := node.value(i)
+	if value == nil {
+		return zeroValue(node.Token, node.IsArray)
+	}
+	val := Eval(value, env)
+	if isError(val) {
+		return val
+	}
+	val, problem := checkType(node.Token, node.IsArray, val)
+	if problem != "" {
+		ident := node.Identifiers[i]
+		return newError(ident.Token, "%s must be a %s, %s", ident.Value, typeString(node.Token, node.IsArray), problem)
+	}
+	return val
+}
+
+// evalConstStatement binds the names for good, the value must fit the type
+// like an argument does
+func evalConstStatement(node *ConstStatement, env *Environment) Object {
+	for i, name := range node.Names {
+		val := Eval(node.Values[i], env)
+		if isError(val) {
+			return val
+		}
+		val, problem := checkType(node.Type, node.IsArray, val)
+		if problem != "" {
+			return newError(name.Token, "FRG_Const %s must be a %s, %s", name.Value, typeString(node.Type, node.IsArray), problem)
+		}
+		if err := env.declare(name); err != nil {
+			return err
+		}
+		env.setConstant(name, val)
+	}
+	return nil
+}
+
+// evalEnumStatement numbers the members from 0, the name of the enum is
+// bound for good like a FRG_Const
+func evalEnumStatement(node *EnumStatement, env *Environment) Object {
+	enum := &Enum{Name: node.Name.Value}
+	for i, member := range node.Members {
+		enum.Members = append(enum.Members, &EnumValue{Enum: enum, Name: member.Value, Value: int64(i)})
+	}
+	if err := env.declare(node.Name); err != nil {
+		return err
+	}
+	env.setConstant(node.Name, enum)
+	return nil
+}
+
+// constKeyword names what made name read-only
+func constKeyword(env *Environment, name string) string {
+	if val, _ := env.Get(name); val != nil && val.Type() == ENUM_OBJ {
+		return "FRG_Enum"
+	}
+	return "FRG_Const"
+}
+
+// enumOperands lets the values of a FRG_Enum be used as numbers, with each
+// other or with an FRG_Int, but never with the values of another enum
+func enumOperands(node *InfixExpression, left, right Object) (Object, Object, Object) {
+	a, leftEnum := left.(*EnumValue)
+	b, rightEnum := right.(*EnumValue)
+	switch {
+	case leftEnum && rightEnum:
+		if a.Enum != b.Enum {
+			return nil, nil, newError(node.Token, "cannot mix %s and %s, they belong to different enums", a.Inspect(), b.Inspect())
 		}
+		return enumNumber(a), enumNumber(b), nil
+	case leftEnum && right.Type() == INTEGER_OBJ:
+		return enumNumber(a), right, nil
+	case rightEnum && left.Type() == INTEGER_OBJ:
+		return left, enumNumber(b), nil
+	}
+	return left, right, nil
+}
+
+// enumNumber gives the number of a FRG_Enum value, the other values are left alone
+func enumNumber(obj Object) Object {
+	if v, ok := obj.(*EnumValue); ok {
+		return &Int{Value: v.Value}
+	}
+	return obj
+}
+
+// enumMismatch refuses to replace the value of an enum by anything but a value of
+// the same enum. a variable is bound to the enum of its first FRG_Enum value, the
+// one it is declared with or the first one assigned to it, and keeps it
+func enumMismatch(tok Token, target string, old, val Object) *Error {
+	a, ok := old.(*EnumValue)
+	if !ok {
+		return nil
+	}
+	if b, ok := val.(*EnumValue); !ok || a.Enum != b.Enum {
+		shown := string(NULL_OBJ)
+		if val != nil {
+			shown = val.Inspect()
+		}
+		return newError(tok, "cannot assign %s to %s, it holds a value of %s", shown, target, a.Enum.Name)
 	}
 	return nil
 }
 
+// evalStaticStatement creates the variables at the first call, the next calls find them
+func evalStaticStatement(node *StaticStatement, env *Environment) Object {
+	if env.statics == nil {
+		return newError(node.Token, "FRG_Static is only allowed inside a FRG_Fn")
+	}
+	var function *Function
+	if n := len(env.runtime.Frames); n > 0 && env.runtime.Frames[n-1].Env == env {
+		function = env.runtime.Frames[n-1].Function
+	}
+	for i, ident := range node.Declaration.Identifiers {
+		if err := env.declare(ident); err != nil {
+			return err
+		}
+		if function != nil {
+			// the parser reports it first, Eval may get a program the parser did not check
+			if conflict := staticConflict(ident.Value, function.Result, function.Parameters); conflict != "" {
+				return newError(ident.Token, "FRG_Static %s cannot have the name of %s of %s", ident.Value, conflict, function.Name)
+			}
+		}
+		if _, ok := env.statics[ident.Value]; !ok {
+			val := initialValue(node.Declaration, i, env)
+			if isError(val) {
+				return val
+			}
+			env.statics[ident.Value] = val
+		}
+	}
+	return nil
+}
+
+// zeroValue is the value of a variable of type typ that was never assigned,
+// reading it is fine (FRG_Static counters start at 0) except for a FRG_Fn
+func zeroValue(typ Token, isArray bool) Object {
+	if isArray {
+		return &Array{Elements: []Object{}}
+	}
+	switch typ.Type {
+	case TokenFRGReal:
+		return &Real{Value: 0}
+	case TokenFRGStrg:
+		return &String{Value: ""}
+	case TokenFRGFn:
+		// no function to start with, reading it before an assignment is an error
+		return nil
+	}
+	return &Int{Value: 0}
+}
+
 func evalAssignmentStatement(node *AssignmentStatement, env *Environment) Object {
 	val := Eval(node.Value, env)
 	if isError(val) {
@@ -312,14 +682,23 @@ func evalAssignmentToExpression(left Expression, val Object, env *Environment) O
 	switch l := left.(type) {
 	case *Identifier:
 		if _, ok := env.Get(l.Value); !ok {
-			return newError(l.Token.Line, l.Token.Column, "cannot assign to undeclared identifier: %s", l.Value)
+			return newError(l.Token, "cannot assign to undeclared identifier: %s", l.Value)
+		}
+		if env.constant(l.Value) {
+			return newError(l.Token, "cannot assign to %s, it is a %s", l.Value, constKeyword(env, l.Value))
+		}
+		old, _ := env.Get(l.Value)
+		if err := enumMismatch(l.Token, l.Value, old, val); err != nil {
+			return err
 		}
 		env.Set(l.Value, val)
 		return nil
 	case *IndexExpression:
 		return evalIndexAssignment(l, val, env)
+	case *MemberExpression:
+		return newError(l.Member.Token, "cannot assign to %s, the names of a module are read-only", l.String())
 	default:
-		return newError(0, 0, "cannot assign to %T", left)
+		return newError(Token{}, "cannot assign to %T", left)
 	}
 }
 
@@ -332,12 +711,23 @@ func evalIndexAssignment(node *IndexExpression, val Object, env *Environment) Ob
 	if isError(index) {
 		return index
 	}
+	index = enumNumber(index)
 	switch {
 	case left.Type() == ARRAY_OBJ && index.Type() == INTEGER_OBJ:
 		array := left.(*Array)
 		idx := index.(*Int).Value
 		if idx < 0 {
-			return newError(node.Token.Line, node.Token.Column, "index out of bounds: %d", idx)
+			return newError(node.Token, "index out of bounds: %d", idx)
+		}
+		if idx < int64(len(array.Elements)) {
+			if err := enumMismatch(node.Token, node.String(), array.Elements[idx], val); err != nil {
+				return err
+			}
+		}
+		if grow := idx + 1 - int64(len(array.Elements)); grow > 0 {
+			if err := env.runtime.allocate(node.Token, grow); err != nil {
+				return err
+			}
 		}
 		// Extend array if necessary
 		for int64(len(array.Elements)) <= idx {
@@ -346,36 +736,68 @@ func evalIndexAssignment(node *IndexExpression, val Object, env *Environment) Ob
 		array.Elements[idx] = val
 		return nil
 	default:
-		return newError(node.Token.Line, node.Token.Column, "cannot assign to index: %s[%s]", left.Type(), index.Type())
+		return newError(node.Token, "cannot assign to index: %s[%s]", left.Type(), index.Type())
 	}
 }
 
+func evalAssertStatement(node *AssertStatement, env *Environment) Object {
+	condition := Eval(node.Condition, env)
+	if isError(condition) {
+		return condition
+	}
+	if isTruthy(condition) {
+		return nil
+	}
+	// without a message show the condition itself
+	message := node.Condition.String()
+	if node.Message != nil {
+		val := Eval(node.Message, env)
+		if isError(val) {
+			return val
+		}
+		message = inspectValue(val)
+	}
+	return newLimitError(ErrorAssertion, node.Token, "assertion failed: %s", message)
+}
+
 func evalPrintStatement(node *PrintStatement, env *Environment) Object {
 	for _, expr := range node.Expressions {
 		val := Eval(expr, env)
+		if isError(val) {
+			return val
+		}
 		if val != nil {
-			fmt.Print(val.Inspect())
+			fmt.Fprint(env.runtime.Out, val.Inspect())
 		}
 	}
 	return nil
 }
 
 func evalInputStatement(node *InputStatement, env *Environment) Object {
-	reader := bufio.NewReader(os.Stdin)
+	if env.runtime.Sandbox {
+		return newLimitError(ErrorSandbox, node.Token, "FRG_Input is not allowed in the sandbox")
+	}
+	reader := env.runtime.In
 
 	for _, expr := range node.Expressions {
 		ident, ok := expr.(*Identifier)
 		if !ok {
-			return newError(node.Token.Line, node.Token.Column, "input statement expects identifiers")
+			return newError(node.Token, "input statement expects identifiers")
 		}
 
 		if _, exists := env.Get(ident.Value); !exists {
-			return newError(ident.Token.Line, ident.Token.Column, "cannot input to undeclared identifier: %s", ident.Value)
+			return newError(ident.Token, "cannot input to undeclared identifier: %s", ident.Value)
+		}
+		if env.constant(ident.Value) {
+			return newError(ident.Token, "cannot read %s with FRG_Input, it is a %s", ident.Value, constKeyword(env, ident.Value))
+		}
+		if old, _ := env.Get(ident.Value); old != nil && old.Type() == ENUM_VALUE_OBJ {
+			return newError(ident.Token, "cannot read %s with FRG_Input, it holds a value of %s", ident.Value, old.(*EnumValue).Enum.Name)
 		}
 
 		input, err := reader.ReadString('\n')
 		if err != nil {
-			return newError(ident.Token.Line, ident.Token.Column, "error reading input: %v", err)
+			return newError(ident.Token, "error reading input: %v", err)
 		}
 
 		input = input[:len(input)-1]
@@ -401,6 +823,9 @@ func evalArrayLiteral(node *ArrayLiteral, env *Environment) Object {
 		}
 		elements = append(elements, evaluated)
 	}
+	if err := env.runtime.allocate(node.Token, int64(len(elements))); err != nil {
+		return err
+	}
 	return &Array{Elements: elements}
 }
 
@@ -410,11 +835,14 @@ func evalArraySizeLiteral(node *ArraySizeLiteral, env *Environment) Object {
 		return sizeObj
 	}
 	if sizeObj.Type() != INTEGER_OBJ {
-		return newError(node.Token.Line, node.Token.Column, "array size must be integer")
+		return newError(node.Token, "array size must be integer")
 	}
 	size := sizeObj.(*Int).Value
 	if size < 0 {
-		return newError(node.Token.Line, node.Token.Column, "array size cannot be negative")
+		return newError(node.Token, "array size cannot be negative")
+	}
+	if err := env.runtime.allocate(node.Token, size); err != nil {
+		return err
 	}
 	elements := make([]Object, size)
 	for i := int64(0); i < size; i++ {
@@ -436,24 +864,60 @@ func evalIndexExpression(node *IndexExpression, env *Environment) Object {
 }
 
 func evalIndexExpressionWithObjects(node *IndexExpression, left, index Object) Object {
+	index = enumNumber(index)
 	switch {
 	case left.Type() == ARRAY_OBJ && index.Type() == INTEGER_OBJ:
 		array := left.(*Array)
 		idx := index.(*Int).Value
 		max := int64(len(array.Elements) - 1)
 		if idx < 0 || idx > max {
-			return newError(node.Token.Line, node.Token.Column, "index out of bounds: %d", idx)
+			return newError(node.Token, "index out of bounds: %d", idx)
 		}
 		return array.Elements[idx]
 	case left.Type() == STRING_OBJ && index.Type() == INTEGER_OBJ:
 		str := left.(*String).Value
 		idx := index.(*Int).Value
 		if idx < 0 || idx >= int64(len(str)) {
-			return newError(node.Token.Line, node.Token.Column, "index out of bounds: %d", idx)
+			return newError(node.Token, "index out of bounds: %d", idx)
 		}
 		return &String{Value: string(str[idx])}
 	default:
-		return newError(node.Token.Line, node.Token.Column, "index operator not supported: %s[%s]", left.Type(), index.Type())
+		return newError(node.Token, "index operator not supported: %s[%s]", left.Type(), index.Type())
+	}
+}
+
+// ErrorKind tells what stopped the program, limits of the runtime have their own kind
+type ErrorKind int
+
+const (
+	ErrorRuntime       ErrorKind = iota // errors of the program itself
+	ErrorStackOverflow                  // MaxCallDepth
+	ErrorStepLimit                      // MaxSteps
+	ErrorTimeout                        // deadline of the Context
+	ErrorCanceled                       // Context canceled
+	ErrorMemoryLimit                    // MaxArrayElements
+	ErrorSandbox                        // FRG_Input or FRG_Use in the sandbox
+	ErrorAssertion                      // Assert with a false condition
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorStackOverflow:
+		return "stack-overflow"
+	case ErrorStepLimit:
+		return "step-limit"
+	case ErrorTimeout:
+		return "timeout"
+	case ErrorCanceled:
+		return "canceled"
+	case ErrorMemoryLimit:
+		return "memory-limit"
+	case ErrorSandbox:
+		return "sandbox"
+	case ErrorAssertion:
+		return "assertion-failed"
+	default:
+		return "runtime-error"
 	}
 }
 
@@ -461,52 +925,395 @@ type Error struct {
 	Message string
 	Line    int
 	Col     int
+	File    string
+	Kind    ErrorKind
+
+	// Stack are the FRG_Fn calls the error went through, the innermost first
+	Stack []StackFrame
+}
+
+// StackFrame is a FRG_Fn call an error went through and where it was called from
+type StackFrame struct {
+	Function string
+	File     string
+	Line     int
+	Col      int
 }
 
 func evalFunctionDeclarationStatement(node *FunctionDeclarationStatement, env *Environment) Object {
 	fn := &Function{
-		Name:       node.Name.Value,
-		Parameters: node.Parameters,
-		ReturnType: node.ReturnType,
-		Body:       node.Body,
-		Env:        env,
+		Name:         node.Name.Value,
+		Parameters:   node.Parameters,
+		ReturnType:   node.ReturnType,
+		ReturnsArray: node.ReturnsArray,
+		Body:         node.Body,
+		Env:          env,
+		Statics:      make(map[string]Object),
+		Result:       node.Name.Value,
+	}
+	if err := env.declare(node.Name); err != nil {
+		return err
 	}
 	env.Set(node.Name.Value, fn)
 	return nil
 }
 
+// evalFunctionLiteral makes a function value, it sees the variables of env like a named one
+func evalFunctionLiteral(node *FunctionLiteral, env *Environment) Object {
+	return &Function{
+		Name:         fmt.Sprintf("FRG_Fn@%d:%d", node.Token.Line, node.Token.Column),
+		Parameters:   node.Function.Parameters,
+		ReturnType:   node.Function.ReturnType,
+		ReturnsArray: node.Function.ReturnsArray,
+		Body:         node.Function.Body,
+		Env:          env,
+		Statics:      make(map[string]Object),
+		Result:       AnonymousResult,
+	}
+}
+
 func evalCallExpression(node *CallExpression, env *Environment) Object {
+	result := evalCall(node, env)
+	if result == VOID {
+		return newError(node.Token, "%s is a FRG_Void function, it gives no value to use", node.Function.String())
+	}
+	return result
+}
+
+// evalCall calls a function, a FRG_Void one gives VOID
+func evalCall(node *CallExpression, env *Environment) Object {
 	fn := Eval(node.Function, env)
 	if isError(fn) {
 		return fn
 	}
-	if fn.Type() != FUNCTION_OBJ {
-		return newError(node.Token.Line, node.Token.Column, "not a function: %s", fn.Type())
+	if builtin, ok := fn.(*Builtin); ok {
+		for _, argument := range node.Arguments {
+			if named, ok := argument.(*NamedArgument); ok {
+				return newError(named.Name.Token, "%s takes no named arguments", builtin.Name)
+			}
+		}
+		args, err := evalArguments(node.Arguments, env)
+		if err != nil {
+			return err
+		}
+		return builtin.Fn(node, env, args)
+	}
+	function, ok := fn.(*Function)
+	if !ok {
+		return newError(node.Token, "not a function: %s", fn.Type())
+	}
+	args, err := bindArguments(function, node, env)
+	if err != nil {
+		return err
+	}
+	return applyFunction(function, args, node, env)
+}
+
+// bindArguments puts the arguments of the call in the order of the parameters,
+// the ones left out stay nil and get their default value in applyFunction.
+// the extra ones go in an array for a variadic parameter
+func bindArguments(function *Function, node *CallExpression, env *Environment) ([]Object, Object) {
+	var positional []Expression
+	var named []*NamedArgument
+	for _, argument := range node.Arguments {
+		if n, ok := argument.(*NamedArgument); ok {
+			named = append(named, n)
+		} else {
+			positional = append(positional, argument)
+		}
+	}
+	values, err := evalArguments(positional, env)
+	if err != nil {
+		return nil, err
+	}
+	args, perr := packArguments(function, values, node)
+	if perr != nil {
+		return nil, perr
+	}
+	for _, argument := range named {
+		index := function.parameterIndex(argument.Name.Value)
+		if index < 0 {
+			return nil, newError(argument.Name.Token, "%s has no parameter named %s", function.Name, argument.Name.Value)
+		}
+		if args[index] != nil {
+			return nil, newError(argument.Name.Token, "argument %s of %s is given twice", argument.Name.Value, function.Name)
+		}
+		val := Eval(argument.Value, env)
+		if isError(val) {
+			return nil, val
+		}
+		if val == nil {
+			val = NULL
+		}
+		args[index] = val
+	}
+	return args, nil
+}
+
+// packArguments gives one slot per parameter to the positional values, the
+// extra ones go in an array for the variadic parameter. the builtins calling
+// a FRG_Fn value use it too
+func packArguments(function *Function, values []Object, node *CallExpression) ([]Object, *Error) {
+	params := function.Parameters
+	fixed := len(params)
+	variadic := fixed > 0 && params[fixed-1].Variadic
+	if variadic {
+		fixed--
+	}
+	if len(values) > fixed && !variadic {
+		return nil, newError(node.Token, "wrong number of arguments: expected %d, got %d", fixed, len(values))
+	}
+	values = append([]Object{}, values...)
+	for i, val := range values {
+		if val == nil {
+			// a variable never assigned, nil would mean a missing argument
+			values[i] = NULL
+		}
+	}
+	args := make([]Object, len(params))
+	copy(args, values)
+	if variadic && len(values) > fixed {
+		args[fixed] = &Array{Elements: values[fixed:]}
 	}
-	function := fn.(*Function)
-	if len(node.Arguments) != len(function.Parameters) {
-		return newError(node.Token.Line, node.Token.Column, "wrong number of arguments: expected %d, got %d", len(function.Parameters), len(node.Arguments))
+	return args, nil
+}
+
+// evalArguments evaluates the arguments in order, numbers... gives every
+// element of the array
+func evalArguments(arguments []Expression, env *Environment) ([]Object, Object) {
+	args := make([]Object, 0, len(arguments))
+	for _, argument := range arguments {
+		if spread, ok := argument.(*SpreadArgument); ok {
+			val := Eval(spread.Value, env)
+			if isError(val) {
+				return nil, val
+			}
+			arr, ok := val.(*Array)
+			if !ok {
+				return nil, newError(spread.Token, "only an array can be spread with ..., got %s", typeName(val))
+			}
+			args = append(args, arr.Elements...)
+			continue
+		}
+		val := Eval(argument, env)
+		if isError(val) {
+			return nil, val
+		}
+		args = append(args, val)
 	}
-	callEnv := NewEnvironment()
-	callEnv.store = make(map[string]Object)
+	return args, nil
+}
+
+// applyFunction runs function with args already evaluated, node is the call
+// that asked for it, directly or through a builtin like map
+func applyFunction(function *Function, args []Object, node *CallExpression, env *Environment) Object {
+	if len(args) > len(function.Parameters) {
+		return newError(node.Token, "wrong number of arguments: expected %d, got %d", len(function.Parameters), len(args))
+	}
+	if max := env.runtime.MaxCallDepth; max > 0 && len(env.runtime.Frames) >= max {
+		return newLimitError(ErrorStackOverflow, startToken(node), "stack overflow in function %s (more than %d nested calls)", function.Name, max)
+	}
+	if err := env.runtime.checkContext(startToken(node)); err != nil {
+		return err
+	}
+	callEnv := &Environment{store: make(map[string]Object), runtime: env.runtime, statics: function.Statics}
 	for k, v := range function.Env.store {
 		callEnv.store[k] = v
 	}
+	if len(function.Env.consts) > 0 {
+		callEnv.consts = make(map[string]*Identifier, len(function.Env.consts))
+		for k := range function.Env.consts {
+			callEnv.consts[k] = nil
+		}
+		// the return value and the parameters are variables of the call
+		delete(callEnv.consts, function.Result)
+		for _, param := range function.Parameters {
+			delete(callEnv.consts, param.Name.Value)
+		}
+	}
+	if function.Result == AnonymousResult {
+		// Result belongs to the anonymous function, not to the code around it
+		callEnv.store[AnonymousResult] = nil
+	}
 	for i, param := range function.Parameters {
-		val := Eval(node.Arguments[i], env)
-		if isError(val) {
-			return val
+		var val Object
+		if i < len(args) {
+			val = args[i]
+		}
+		if param.Variadic {
+			val, err := checkVariadic(function, param, val, node)
+			if err != nil {
+				return err
+			}
+			// a new array at every call
+			if err := env.runtime.allocate(node.Token, int64(len(val.(*Array).Elements))); err != nil {
+				return err
+			}
+			callEnv.Set(param.Name.Value, val)
+			continue
+		}
+		if val == nil {
+			if param.Default == nil {
+				return newError(node.Token, "missing argument %s of %s", param.Name.Value, function.Name)
+			}
+			// computed at every call, it can use the parameters before it
+			val = Eval(param.Default, callEnv)
+			if isError(val) {
+				return val
+			}
+		}
+		val, err := checkArgument(function, param, val, node)
+		if err != nil {
+			return err
 		}
 		callEnv.Set(param.Name.Value, val)
 	}
+	env.runtime.pushFrame(&Frame{Function: function, Call: node, Env: callEnv})
 	result := Eval(function.Body, callEnv)
-	if retVal, ok := callEnv.Get(function.Name); ok && retVal != nil {
-		return retVal
+	env.runtime.popFrame()
+	if err, ok := result.(*Error); ok {
+		// remember the call for the traceback
+		call := startToken(node)
+		err.Stack = append(err.Stack, StackFrame{Function: function.Name, File: call.File, Line: call.Line, Col: call.Column})
+		return err
 	}
-	return result
+	if function.ReturnType.Type == TokenFRGVoid {
+		return VOID
+	}
+	retVal, _ := callEnv.Get(function.Result)
+	if retVal == nil || retVal == function {
+		// the slot still holds the function itself, or nothing for an anonymous one
+		return newError(node.Token, "%s ended without assigning its return value to %s", function.Name, function.Result)
+	}
+	retVal, problem := checkType(function.ReturnType, function.ReturnsArray, retVal)
+	if problem != "" {
+		return newError(node.Token, "%s must return a %s, %s", function.Name, typeString(function.ReturnType, function.ReturnsArray), problem)
+	}
+	return retVal
+}
+
+// checkVariadic checks the extra arguments one by one, they are copied in a
+// new array so an FRG_Int can still become an FRG_Real
+func checkVariadic(function *Function, param *Parameter, val Object, node *CallExpression) (Object, *Error) {
+	if val == nil {
+		return &Array{Elements: []Object{}}, nil
+	}
+	arr, ok := val.(*Array)
+	if !ok {
+		// only a named argument gives the whole array at once
+		return nil, newError(node.Token, "argument %s of %s must be an array, got %s", param.Name.Value, function.Name, typeName(val))
+	}
+	elements := make([]Object, len(arr.Elements))
+	for i, element := range arr.Elements {
+		converted, problem := checkType(param.Type, param.IsArray, element)
+		if problem != "" {
+			return nil, newError(node.Token, "argument %s of %s must be a %s, value %d %s", param.Name.Value, function.Name, param.TypeString(), i, problem)
+		}
+		elements[i] = converted
+	}
+	return &Array{Elements: elements}, nil
+}
+
+func checkArgument(function *Function, param *Parameter, val Object, node *CallExpression) (Object, *Error) {
+	val, problem := checkType(param.Type, param.IsArray, val)
+	if problem != "" {
+		return nil, newError(node.Token, "argument %s of %s must be a %s, %s", param.Name.Value, function.Name, param.TypeString(), problem)
+	}
+	return val, nil
+}
+
+// checkType makes sure val is a typ, or an array of typ, and says what is
+// wrong when it is not. an FRG_Int is accepted for an FRG_Real and converted,
+// inside an array it is accepted but left alone: arrays are passed by
+// reference, the function changes the array of the caller
+func checkType(typ Token, isArray bool, val Object) (Object, string) {
+	if !isArray {
+		if converted, ok := convertScalar(typ.Type, val); ok {
+			return converted, ""
+		}
+		return nil, fmt.Sprintf("got %s", typeName(val))
+	}
+	arr, ok := val.(*Array)
+	if !ok {
+		return nil, fmt.Sprintf("got %s", typeName(val))
+	}
+	for i, element := range arr.Elements {
+		if _, ok := convertScalar(typ.Type, element); !ok {
+			return nil, fmt.Sprintf("element %d is a %s", i, typeName(element))
+		}
+	}
+	return arr, ""
+}
+
+func convertScalar(typ TokenType, val Object) (Object, bool) {
+	switch v := val.(type) {
+	case *Int:
+		if typ == TokenFRGReal {
+			return &Real{Value: float64(v.Value)}, true
+		}
+		return v, typ == TokenFRGInt
+	case *Real:
+		return v, typ == TokenFRGReal
+	case *String:
+		return v, typ == TokenFRGStrg
+	case *Boolean:
+		// there is no boolean type, a comparison is kept in an FRG_Int
+		return v, typ == TokenFRGInt
+	case *EnumValue:
+		// the values of an enum are numbers too
+		return v, typ == TokenFRGInt
+	case *Function, *Builtin:
+		return v, typ == TokenFRGFn
+	}
+	return val, false
+}
+
+func typeName(val Object) ObjectType {
+	if val == nil {
+		return NULL_OBJ
+	}
+	if v, ok := val.(*EnumValue); ok {
+		return ObjectType(v.Enum.Name)
+	}
+	return val.Type()
 }
 
 func (e *Error) Type() ObjectType { return "ERROR" }
 func (e *Error) Inspect() string {
 	return fmt.Sprintf("ERROR: %s (line %d, col %d)", e.Message, e.Line, e.Col)
 }
+
+// Traceback returns the FRG_Fn calls that led to the error, one per line:
+//
+//	at pow (std.frg:92:13) called from main.frg:17:15
+//	at area (main.frg:17:15) called from main.frg:30:5
+func (e *Error) Traceback() string {
+	var out strings.Builder
+	file, line, col := e.File, e.Line, e.Col
+	previous, repeated := "", 0
+	for _, frame := range e.Stack {
+		entry := fmt.Sprintf("    at %s (%s) called from %s\n", frame.Function, position(file, line, col), position(frame.File, frame.Line, frame.Col))
+		file, line, col = frame.File, frame.Line, frame.Col
+		// a recursion gives the same line again and again
+		if entry == previous {
+			repeated++
+			continue
+		}
+		if repeated > 0 {
+			fmt.Fprintf(&out, "    ... the line above repeated %d more times\n", repeated)
+		}
+		out.WriteString(entry)
+		previous, repeated = entry, 0
+	}
+	if repeated > 0 {
+		fmt.Fprintf(&out, "    ... the line above repeated %d more times\n", repeated)
+	}
+	return out.String()
+}
+
+func position(file string, line, col int) string {
+	if file == "" {
+		return fmt.Sprintf("%d:%d", line, col)
+	}
+	return fmt.Sprintf("%s:%d:%d", file, line, col)
+}
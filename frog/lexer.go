@@ -50,6 +50,8 @@ const (
 	TokenLBracket  // [
 	TokenRBracket  // ]
 	TokenHash      // #
+	TokenDot       // .
+	TokenEllipsis  // ...
 
 	TokenFRGBegin
 	TokenFRGEnd
@@ -70,29 +72,47 @@ const (
 	TokenTrue
 	TokenFalse
 	TokenFRGUse
+	TokenAssert
+	TokenFRGExport
+	TokenAs
+	TokenFrom
+	TokenFRGStatic
+	TokenFRGVoid
+	TokenFRGConst
+	TokenFRGEnum
+
+	TokenComment // ## ... (recorded by the lexer, never returned by NextToken)
 )
 
 // map of keywords and thier tokens
 var keywords = map[string]TokenType{
-	"FRG_Begin": TokenFRGBegin,
-	"FRG_End":   TokenFRGEnd,
-	"FRG_Int":   TokenFRGInt,
-	"FRG_Real":  TokenFRGReal,
-	"FRG_Strg":  TokenFRGStrg,
-	"FRG_Fn":    TokenFRGFn,
-	"FRG_Print": TokenFRGPrint,
-	"FRG_Input": TokenFRGInput,
-	"If":        TokenIf,
-	"Else":      TokenElse,
-	"Begin":     TokenBegin,
-	"End":       TokenEnd,
-	"Repeat":    TokenRepeat,
-	"Until":     TokenUntil,
-	"Break":     TokenBreak,
-	"Continue":  TokenContinue,
-	"True":      TokenTrue,
-	"False":     TokenFalse,
-	"FRG_Use":   TokenFRGUse,
+	"FRG_Begin":  TokenFRGBegin,
+	"FRG_End":    TokenFRGEnd,
+	"FRG_Int":    TokenFRGInt,
+	"FRG_Real":   TokenFRGReal,
+	"FRG_Strg":   TokenFRGStrg,
+	"FRG_Fn":     TokenFRGFn,
+	"FRG_Print":  TokenFRGPrint,
+	"FRG_Input":  TokenFRGInput,
+	"If":         TokenIf,
+	"Else":       TokenElse,
+	"Begin":      TokenBegin,
+	"End":        TokenEnd,
+	"Repeat":     TokenRepeat,
+	"Until":      TokenUntil,
+	"Break":      TokenBreak,
+	"Continue":   TokenContinue,
+	"True":       TokenTrue,
+	"False":      TokenFalse,
+	"FRG_Use":    TokenFRGUse,
+	"Assert":     TokenAssert,
+	"FRG_Export": TokenFRGExport,
+	"As":         TokenAs,
+	"From":       TokenFrom,
+	"FRG_Static": TokenFRGStatic,
+	"FRG_Void":   TokenFRGVoid,
+	"FRG_Const":  TokenFRGConst,
+	"FRG_Enum":   TokenFRGEnum,
 }
 
 type Token struct {
@@ -104,6 +124,8 @@ type Token struct {
 	Line int
 	// Column of the token
 	Column int
+	// File the token comes from (empty when the source has no name)
+	File string
 }
 
 func (t Token) String() string {
@@ -112,6 +134,7 @@ func (t Token) String() string {
 }
 
 type Lexer struct {
+	file         string // source file name, copied to every token
 	input        string
 	position     int // current position
 	readPosition int // next position
@@ -123,6 +146,9 @@ type Lexer struct {
 	ch     rune
 	line   int
 	column int
+
+	// comments skipped so far, kept for tools that need them (linter, editors)
+	comments []Token
 }
 
 // constructor
@@ -136,7 +162,26 @@ func NewLexer(input string) *Lexer {
 	return l
 }
 
+// NewFileLexer is NewLexer for a source read from a file,
+// the tokens remember the file name for error messages
+func NewFileLexer(filename, input string) *Lexer {
+	l := NewLexer(input)
+	l.file = filename
+	return l
+}
+
+// File returns the name of the source file (empty for NewLexer)
+func (l *Lexer) File() string {
+	return l.file
+}
+
 func (l *Lexer) NextToken() Token {
+	tok := l.nextToken()
+	tok.File = l.file
+	return tok
+}
+
+func (l *Lexer) nextToken() Token {
 	var tok Token
 
 	l.skipWhitespace()
@@ -211,6 +256,14 @@ func (l *Lexer) NextToken() Token {
 		tok = Token{Type: TokenSlash, Literal: string(l.ch), Line: line, Column: column}
 	case '%':
 		tok = Token{Type: TokenModulo, Literal: string(l.ch), Line: line, Column: column}
+	case '.':
+		if strings.HasPrefix(l.input[l.position:], "...") {
+			l.readChar()
+			l.readChar()
+			tok = Token{Type: TokenEllipsis, Literal: "...", Line: line, Column: column}
+		} else {
+			tok = Token{Type: TokenDot, Literal: string(l.ch), Line: line, Column: column}
+		}
 	case ',':
 		tok = Token{Type: TokenComma, Literal: string(l.ch), Line: line, Column: column}
 	case ';':
@@ -230,14 +283,21 @@ func (l *Lexer) NextToken() Token {
 	case '#':
 		// Check for comment (##)
 		if l.peekChar() == '#' {
-			l.skipComment()
-			return l.NextToken()
+			l.skipComment(line, column)
+			return l.nextToken()
 		} else {
 			tok = Token{Type: TokenHash, Literal: string(l.ch), Line: line, Column: column}
 		}
 	case '"':
+		start := l.position
+		str, closed := l.readString()
 		tok.Type = TokenString
-		tok.Literal = l.readString()
+		tok.Literal = str
+		if !closed {
+			// the file ends inside the string, the parser reports it
+			tok.Type = TokenIllegal
+			tok.Literal, _, _ = strings.Cut(l.input[start:min(l.position, len(l.input))], "\n")
+		}
 		tok.Line = line
 		tok.Column = column
 	case 0:
@@ -296,10 +356,12 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
-func (l *Lexer) skipComment() {
+func (l *Lexer) skipComment(line, column int) {
+	position := l.position
 	for l.ch != '\n' && l.ch != 0 {
 		l.readChar()
 	}
+	l.comments = append(l.comments, Token{Type: TokenComment, Literal: l.input[position:l.position], Line: line, Column: column, File: l.file})
 	l.skipWhitespace()
 }
 
@@ -328,7 +390,8 @@ func (l *Lexer) readNumber() string {
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readString() string {
+// readString reads up to the closing ", closed is false when the input ends first
+func (l *Lexer) readString() (str string, closed bool) {
 	position := l.position + 1
 	for {
 		l.readChar()
@@ -337,14 +400,19 @@ func (l *Lexer) readString() string {
 		}
 		if l.ch == '\\' {
 			l.readChar()
+			if l.ch == 0 {
+				// "a\ at the end of a half typed file
+				break
+			}
 		}
 	}
-	str := l.input[position:l.position]
+	// past the end readChar keeps moving position
+	str = l.input[position:min(l.position, len(l.input))]
 	str = strings.ReplaceAll(str, "\\n", "\n")
 	str = strings.ReplaceAll(str, "\\t", "\t")
 	str = strings.ReplaceAll(str, "\\\"", "\"")
 	str = strings.ReplaceAll(str, "\\\\", "\\")
-	return str
+	return str, l.ch == '"'
 }
 
 func (l *Lexer) GetAllTokens() []Token {
@@ -359,10 +427,16 @@ func (l *Lexer) GetAllTokens() []Token {
 	return tokens
 }
 
+// Comments returns the ## comments the lexer has skipped so far
+func (l *Lexer) Comments() []Token {
+	return l.comments
+}
+
 func (l *Lexer) Reset() {
 	l.position = 0
 	l.readPosition = 0
 	l.line = 1
 	l.column = 0
+	l.comments = nil
 	l.readChar()
 }
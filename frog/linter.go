@@ -0,0 +1,571 @@
+// Copyright (C) by abdenour souane
+// you have a right to modify it upgrade it or do whatever you want
+// but u have to keep my name on it
+
+package frog
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// LintRule is a named check that runs over the whole AST
+type LintRule struct {
+	ID          string
+	Description string
+	check       func(lc *lintContext)
+}
+
+// every rule the linter knows about, in the order they run
+var lintRules = []*LintRule{
+	{ID: "unused-variable", Description: "variable is declared but never read", check: checkUnusedVariables},
+	{ID: "unreached-return", Description: "function never assigns its return value on a reachable path", check: checkUnreachedReturns},
+	{ID: "constant-until", Description: "Repeat loop whose Until condition can never change", check: checkConstantUntil},
+	{ID: "shadowed-declaration", Description: "declaration hides or repeats another declaration", check: checkShadowedDeclarations},
+	{ID: "unreachable-code", Description: "statement placed after Break# or Continue#", check: checkUnreachableCode},
+}
+
+// LintRules returns all the available rules
+func LintRules() []*LintRule {
+	rules := make([]*LintRule, len(lintRules))
+	copy(rules, lintRules)
+	return rules
+}
+
+func findLintRule(id string) *LintRule {
+	for _, rule := range lintRules {
+		if rule.ID == id {
+			return rule
+		}
+	}
+	return nil
+}
+
+type Linter struct {
+	disabled map[string]bool
+}
+
+// constructor
+func NewLinter() *Linter {
+	return &Linter{disabled: make(map[string]bool)}
+}
+
+// Disable turns a rule off for every program checked by this linter
+func (l *Linter) Disable(id string) error {
+	if findLintRule(id) == nil {
+		return fmt.Errorf("unknown lint rule: %s", id)
+	}
+	l.disabled[id] = true
+	return nil
+}
+
+// Lint runs every enabled rule over the program and returns the issues sorted by position,
+// they are warnings whose Code is the rule id.
+// comments are the ones collected by the lexer of the same source (Lexer.Comments),
+// they carry the ## frog:ignore directives:
+//
+//	## frog:ignore rule-id, other-id   -> ignore on this line and the next one
+//	## frog:ignore                     -> ignore every rule on this line and the next one
+//	## frog:ignore-file rule-id        -> ignore in the whole file
+func (l *Linter) Lint(program *Program, comments []Token) []Diagnostic {
+	lc := newLintContext(program, comments)
+	for _, rule := range lintRules {
+		if l.disabled[rule.ID] || lc.ignoreFile["*"] || lc.ignoreFile[rule.ID] {
+			continue
+		}
+		lc.rule = rule.ID
+		rule.check(lc)
+	}
+	sort.SliceStable(lc.issues, func(i, j int) bool {
+		a, b := lc.issues[i], lc.issues[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return lc.issues
+}
+
+// =============================================================================
+// lint context : state shared by the rules while checking one program
+// =============================================================================
+
+type declKind int
+
+const (
+	declVariable declKind = iota
+	declParameter
+	declFunction
+	declModule
+	declEnum
+	declEnumMember // only in the language server, the members are reached through their enum
+)
+
+type lintDecl struct {
+	ident    *Identifier
+	kind     declKind
+	included bool // comes from a FRG_Use file (FRG_Use ... From)
+	used     bool
+}
+
+// frog has two kinds of scopes: the global one and one per function call
+// (Begin/End blocks do not open a new scope in the interpreter)
+type lintScope struct {
+	parent *lintScope
+	decls  map[string]*lintDecl
+	order  []*lintDecl
+}
+
+func (s *lintScope) lookup(name string) *lintDecl {
+	for ; s != nil; s = s.parent {
+		if d, ok := s.decls[name]; ok {
+			return d
+		}
+	}
+	return nil
+}
+
+type lintContext struct {
+	program *Program
+	rule    string // rule being checked right now
+	issues  []Diagnostic
+
+	ignore     map[int]map[string]bool // line -> ignored rule ids ("*" means all)
+	ignoreFile map[string]bool
+
+	// built lazily by scopes()
+	global     *lintScope
+	funcs      []*FunctionDeclarationStatement
+	funcScopes map[*FunctionDeclarationStatement]*lintScope
+	redeclared [][2]*lintDecl // {first, again}
+}
+
+func newLintContext(program *Program, comments []Token) *lintContext {
+	lc := &lintContext{
+		program:    program,
+		ignore:     make(map[int]map[string]bool),
+		ignoreFile: make(map[string]bool),
+	}
+	for _, c := range comments {
+		text := strings.TrimSpace(strings.TrimLeft(c.Literal, "#"))
+		switch {
+		case strings.HasPrefix(text, "frog:ignore-file"):
+			for _, id := range lintDirectiveIDs(strings.TrimPrefix(text, "frog:ignore-file")) {
+				lc.ignoreFile[id] = true
+			}
+		case strings.HasPrefix(text, "frog:ignore"):
+			ids := lintDirectiveIDs(strings.TrimPrefix(text, "frog:ignore"))
+			for _, line := range []int{c.Line, c.Line + 1} {
+				if lc.ignore[line] == nil {
+					lc.ignore[line] = make(map[string]bool)
+				}
+				for _, id := range ids {
+					lc.ignore[line][id] = true
+				}
+			}
+		}
+	}
+	return lc
+}
+
+// lintDirectiveIDs splits "a, b c" into rule ids, no ids at all means every rule
+func lintDirectiveIDs(text string) []string {
+	ids := strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+	if len(ids) == 0 {
+		return []string{"*"}
+	}
+	return ids
+}
+
+func (lc *lintContext) report(tok Token, format string, a ...interface{}) {
+	if ids, ok := lc.ignore[tok.Line]; ok && (ids["*"] || ids[lc.rule]) {
+		return
+	}
+	lc.issues = append(lc.issues, newDiagnostic(SeverityWarning, tok, lc.rule, format, a...))
+}
+
+// inspectOwn is inspect without the modules read by FRG_Use,
+// their statements belong to another file so we never report anything inside them
+func inspectOwn(node Node, fn func(Node) bool) {
+	inspect(node, func(n Node) bool {
+		if _, ok := n.(*UseStatement); ok {
+			fn(n)
+			return false
+		}
+		return fn(n)
+	})
+}
+
+func (lc *lintContext) scopes() *lintScope {
+	if lc.global != nil {
+		return lc.global
+	}
+	lc.global = &lintScope{decls: make(map[string]*lintDecl)}
+	lc.funcScopes = make(map[*FunctionDeclarationStatement]*lintScope)
+	lc.collectScope(lc.program, lc.global, false)
+	lc.markUses(lc.program, lc.global)
+	return lc.global
+}
+
+func (lc *lintContext) declare(scope *lintScope, ident *Identifier, kind declKind, included bool) {
+	d := &lintDecl{ident: ident, kind: kind, included: included}
+	if first, ok := scope.decls[ident.Value]; ok {
+		lc.redeclared = append(lc.redeclared, [2]*lintDecl{first, d})
+		return
+	}
+	scope.decls[ident.Value] = d
+	scope.order = append(scope.order, d)
+}
+
+func (lc *lintContext) collectScope(node Node, scope *lintScope, included bool) {
+	inspect(node, func(n Node) bool {
+		switch n := n.(type) {
+		case *UseStatement:
+			if n.Alias != nil {
+				lc.declare(scope, n.Alias, declModule, false)
+			}
+			for _, name := range n.Names {
+				lc.declare(scope, name, declVariable, true)
+			}
+			return false
+		case *ExportStatement:
+			lc.collectScope(n.Statement, scope, included)
+			// the files using this one read them
+			for _, name := range n.Names() {
+				if d := scope.decls[name.Value]; d != nil {
+					d.used = true
+				}
+			}
+			return false
+		case *DeclarationStatement:
+			for i, ident := range n.Identifiers {
+				lc.declare(scope, ident, declVariable, included)
+				if value := n.value(i); value != nil {
+					lc.collectScope(value, scope, included)
+				}
+			}
+			return false
+		case *ConstStatement:
+			for _, name := range n.Names {
+				lc.declare(scope, name, declVariable, included)
+			}
+			// the values may hold anonymous functions
+			for _, value := range n.Values {
+				lc.collectScope(value, scope, included)
+			}
+			return false
+		case *EnumStatement:
+			lc.declare(scope, n.Name, declEnum, included)
+			return false
+		case *FunctionDeclarationStatement:
+			lc.declare(scope, n.Name, declFunction, included)
+			lc.collectFunction(n, scope, included)
+			return false
+		case *FunctionLiteral:
+			// the Result of an anonymous function is not a name of the scope around it
+			lc.collectFunction(n.Function, scope, included)
+			return false
+		}
+		return true
+	})
+}
+
+func (lc *lintContext) collectFunction(fn *FunctionDeclarationStatement, scope *lintScope, included bool) {
+	fnScope := &lintScope{parent: scope, decls: make(map[string]*lintDecl)}
+	lc.funcs = append(lc.funcs, fn)
+	lc.funcScopes[fn] = fnScope
+	for _, param := range fn.Parameters {
+		lc.declare(fnScope, param.Name, declParameter, included)
+	}
+	lc.collectScope(fn.Body, fnScope, included)
+}
+
+// markUses flags every declaration that is read somewhere
+func (lc *lintContext) markUses(node Node, scope *lintScope) {
+	inspect(node, func(n Node) bool {
+		switch n := n.(type) {
+		case *FunctionDeclarationStatement:
+			for _, param := range n.Parameters {
+				if param.Default != nil {
+					lc.markUses(param.Default, lc.funcScopes[n])
+				}
+			}
+			lc.markUses(n.Body, lc.funcScopes[n])
+			return false
+		case *InputStatement, *UseStatement, *EnumStatement:
+			// declared or written, not read
+			return false
+		case *DeclarationStatement:
+			// only the initializers are read
+			for i := range n.Identifiers {
+				if value := n.value(i); value != nil {
+					lc.markUses(value, scope)
+				}
+			}
+			return false
+		case *ConstStatement:
+			for _, value := range n.Values {
+				lc.markUses(value, scope)
+			}
+			return false
+		case *MemberExpression:
+			// the member belongs to the module or the enum
+			lc.markUses(n.Object, scope)
+			return false
+		case *AssignmentStatement:
+			if _, ok := n.Left.(*Identifier); !ok {
+				// a[i] := ... reads a and i
+				lc.markUses(n.Left, scope)
+			}
+			lc.markUses(n.Value, scope)
+			return false
+		case *Identifier:
+			if d := scope.lookup(n.Value); d != nil {
+				d.used = true
+			}
+		}
+		return true
+	})
+}
+
+// =============================================================================
+// rules
+// =============================================================================
+
+func checkUnusedVariables(lc *lintContext) {
+	global := lc.scopes()
+	scopes := []*lintScope{global}
+	for _, fn := range lc.funcs {
+		scopes = append(scopes, lc.funcScopes[fn])
+	}
+	for _, scope := range scopes {
+		for _, d := range scope.order {
+			if d.kind == declVariable && !d.included && !d.used {
+				lc.report(d.ident.Token, "variable '%s' is declared but never used", d.ident.Value)
+			}
+		}
+	}
+}
+
+func checkShadowedDeclarations(lc *lintContext) {
+	global := lc.scopes()
+	for _, pair := range lc.redeclared {
+		first, again := pair[0], pair[1]
+		if again.included {
+			continue
+		}
+		if first.included {
+			lc.report(again.ident.Token, "'%s' redeclares a name from an included file", again.ident.Value)
+		} else {
+			lc.report(again.ident.Token, "'%s' is already declared at line %d", again.ident.Value, first.ident.Token.Line)
+		}
+	}
+	for _, fn := range lc.funcs {
+		for _, d := range lc.funcScopes[fn].order {
+			if d.included {
+				continue
+			}
+			outer := global.lookup(d.ident.Value)
+			if outer == nil {
+				continue
+			}
+			if outer.kind == declFunction && outer.ident.Value == fn.Name.Value {
+				lc.report(d.ident.Token, "'%s' hides the return value of function '%s'", d.ident.Value, fn.Name.Value)
+			} else if outer.included {
+				lc.report(d.ident.Token, "'%s' shadows a global from an included file", d.ident.Value)
+			} else {
+				lc.report(d.ident.Token, "'%s' shadows the global declared at line %d", d.ident.Value, outer.ident.Token.Line)
+			}
+		}
+	}
+}
+
+func isJumpStatement(s Statement) bool {
+	switch s.(type) {
+	case *BreakStatement, *ContinueStatement:
+		return true
+	}
+	return false
+}
+
+func checkUnreachableCode(lc *lintContext) {
+	inspectOwn(lc.program, func(n Node) bool {
+		var list []Statement
+		switch n := n.(type) {
+		case *Program:
+			list = n.Statements
+		case *BlockStatement:
+			list = n.Statements
+		case *RepeatStatement:
+			list = n.Body
+		}
+		for i := 0; i < len(list)-1; i++ {
+			if isJumpStatement(list[i]) {
+				lc.report(startToken(list[i+1]), "unreachable code after %s#", list[i].TokenLiteral())
+				break
+			}
+		}
+		return true
+	})
+}
+
+func checkUnreachedReturns(lc *lintContext) {
+	inspectOwn(lc.program, func(n Node) bool {
+		fn, ok := n.(*FunctionDeclarationStatement)
+		if !ok || fn.Body == nil || fn.Void() {
+			return true
+		}
+		reached, anywhere := assignsReturnSlot(fn.Body.Statements, fn.Name.Value)
+		if reached {
+			return true
+		}
+		if fn.Anonymous() {
+			if anywhere {
+				lc.report(fn.Token, "assignment to Result of the anonymous function is never reached")
+			} else {
+				lc.report(fn.Token, "anonymous function never assigns Result")
+			}
+		} else if anywhere {
+			lc.report(fn.Name.Token, "assignment to the return value of '%s' is never reached", fn.Name.Value)
+		} else {
+			lc.report(fn.Name.Token, "function '%s' never assigns its return value", fn.Name.Value)
+		}
+		return true
+	})
+}
+
+// assignsReturnSlot reports if the statements assign to name on a reachable path,
+// and if they assign to it anywhere at all
+func assignsReturnSlot(stmts []Statement, name string) (reached, anywhere bool) {
+	dead := false
+	for _, s := range stmts {
+		r, a := assignsReturnSlotIn(s, name)
+		anywhere = anywhere || a
+		reached = reached || (r && !dead)
+		if isJumpStatement(s) {
+			dead = true
+		}
+	}
+	return reached, anywhere
+}
+
+func assignsReturnSlotIn(s Statement, name string) (reached, anywhere bool) {
+	if isNilNode(s) {
+		return false, false
+	}
+	switch s := s.(type) {
+	case *AssignmentStatement:
+		if ident, ok := s.Left.(*Identifier); ok && ident.Value == name {
+			return true, true
+		}
+	case *IfStatement:
+		r1, a1 := assignsReturnSlotIn(s.Consequence, name)
+		r2, a2 := assignsReturnSlotIn(s.Alternative, name)
+		return r1 || r2, a1 || a2
+	case *BlockStatement:
+		return assignsReturnSlot(s.Statements, name)
+	case *RepeatStatement:
+		return assignsReturnSlot(s.Body, name)
+	}
+	return false, false
+}
+
+func checkConstantUntil(lc *lintContext) {
+	inspectOwn(lc.program, func(n Node) bool {
+		rs, ok := n.(*RepeatStatement)
+		if !ok || isNilNode(rs.Condition) {
+			return true
+		}
+		// Until [True] is the "run once" idiom
+		if b, ok := rs.Condition.(*Boolean); ok && b.Value {
+			return true
+		}
+
+		names := make(map[string]bool)
+		hasCall, hasIndex := false, false
+		inspect(rs.Condition, func(n Node) bool {
+			switch n := n.(type) {
+			case *Identifier:
+				names[n.Value] = true
+			case *CallExpression:
+				hasCall = true
+			case *IndexExpression:
+				hasIndex = true
+			}
+			return true
+		})
+		if hasCall {
+			// a function result can change between iterations
+			return true
+		}
+
+		changed, bodyCalls := false, false
+		for _, s := range rs.Body {
+			inspect(s, func(n Node) bool {
+				switch n := n.(type) {
+				case *AssignmentStatement:
+					if names[assignedName(n.Left)] {
+						changed = true
+					}
+				case *InputStatement:
+					for _, e := range n.Expressions {
+						if names[assignedName(e)] {
+							changed = true
+						}
+					}
+				case *CallExpression:
+					bodyCalls = true
+				case *FunctionDeclarationStatement:
+					return false
+				}
+				return true
+			})
+		}
+		// arrays are shared with the called functions
+		if changed || (hasIndex && bodyCalls) || containsBreak(rs.Body) {
+			return true
+		}
+		lc.report(rs.Token, "Until condition %s never changes inside the Repeat and there is no Break#", rs.Condition.String())
+		return true
+	})
+}
+
+// assignedName returns the variable written by an assignment target (x, x[i], x[i][j])
+func assignedName(e Expression) string {
+	for {
+		switch n := e.(type) {
+		case *Identifier:
+			return n.Value
+		case *IndexExpression:
+			e = n.Left
+		default:
+			return ""
+		}
+	}
+}
+
+// containsBreak reports a Break# that leaves the current loop,
+// breaks of nested Repeat loops belong to them
+func containsBreak(stmts []Statement) bool {
+	for _, s := range stmts {
+		if isNilNode(s) {
+			continue
+		}
+		switch s := s.(type) {
+		case *BreakStatement:
+			return true
+		case *BlockStatement:
+			if containsBreak(s.Statements) {
+				return true
+			}
+		case *IfStatement:
+			if containsBreak([]Statement{s.Consequence, s.Alternative}) {
+				return true
+			}
+		}
+	}
+	return false
+}
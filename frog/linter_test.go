@@ -0,0 +1,103 @@
+// Copyright (C) by abdenour souane
+// you have a right to modify it upgrade it or do whatever you want
+// but u have to keep my name on it
+
+package frog
+
+import (
+	"fmt"
+	"testing"
+)
+
+// every rule with a program it reports once, %s is the comment line
+// right above what is reported
+var lintCases = []struct {
+	rule, code string
+}{
+	{"unused-variable", `FRG_Begin
+    %s
+    FRG_Int unused#
+FRG_End
+`},
+	{"unreached-return", `FRG_Begin
+    %s
+    FRG_Fn never(FRG_Int x) : FRG_Int
+    Begin
+        FRG_Print x#
+    End
+    FRG_Print never(1)#
+FRG_End
+`},
+	{"constant-until", `FRG_Begin
+    FRG_Int i#
+    i := 0#
+    %s
+    Repeat
+        FRG_Print i#
+    Until [i > 3]
+FRG_End
+`},
+	{"shadowed-declaration", `FRG_Begin
+    FRG_Int i#
+    i := 1#
+    FRG_Print i#
+    %s
+    FRG_Int i#
+    i := 2#
+    FRG_Print i#
+FRG_End
+`},
+	{"unreachable-code", `FRG_Begin
+    Repeat
+        Break#
+        %s
+        FRG_Print "dead"#
+    Until [False]
+FRG_End
+`},
+}
+
+func lintSource(t *testing.T, code string) []Diagnostic {
+	t.Helper()
+	lexer := NewFileLexer("lint.frg", code)
+	parser := NewParser(lexer)
+	program := parser.ParseProgram()
+	if parser.IsThereAnyErrors() {
+		t.Fatalf("does not parse: %s\n%s", parser.Diagnostics()[0].Message, code)
+	}
+	return NewLinter().Lint(program, lexer.Comments())
+}
+
+func TestLintRules(t *testing.T) {
+	if len(lintCases) != len(LintRules()) {
+		t.Errorf("%d rules tested, there are %d", len(lintCases), len(LintRules()))
+	}
+	for _, c := range lintCases {
+		t.Run(c.rule, func(t *testing.T) {
+			issues := lintSource(t, fmt.Sprintf(c.code, "## nothing to ignore"))
+			if len(issues) != 1 || issues[0].Code != c.rule || issues[0].Severity != SeverityWarning {
+				t.Fatalf("got %v, want one %s warning", issues, c.rule)
+			}
+			for _, directive := range []string{"## frog:ignore " + c.rule, "## frog:ignore other-rule, " + c.rule, "## frog:ignore"} {
+				if issues := lintSource(t, fmt.Sprintf(c.code, directive)); len(issues) != 0 {
+					t.Errorf("%s: got %v, want nothing", directive, issues)
+				}
+			}
+			if issues := lintSource(t, fmt.Sprintf(c.code, "## frog:ignore other-rule")); len(issues) != 1 {
+				t.Errorf("another rule ignored: got %v, want the %s warning", issues, c.rule)
+			}
+			if issues := lintSource(t, "## frog:ignore-file "+c.rule+"\n"+fmt.Sprintf(c.code, "")); len(issues) != 0 {
+				t.Errorf("frog:ignore-file: got %v, want nothing", issues)
+			}
+
+			linter := NewLinter()
+			if err := linter.Disable(c.rule); err != nil {
+				t.Fatal(err)
+			}
+			lexer := NewFileLexer("lint.frg", fmt.Sprintf(c.code, ""))
+			if issues := linter.Lint(NewParser(lexer).ParseProgram(), lexer.Comments()); len(issues) != 0 {
+				t.Errorf("disabled: got %v, want nothing", issues)
+			}
+		})
+	}
+}
@@ -0,0 +1,606 @@
+// Copyright (C) by abdenour souane
+// you have a right to modify it upgrade it or do whatever you want
+// but u have to keep my name on it
+
+package frog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// language server protocol : https://microsoft.github.io/language-server-protocol/
+// only the small subset editors need for frog is implemented
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result"`
+}
+
+type rpcErrorResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Error   *rpcError       `json:"error"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+type rpcNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+// json-rpc error codes
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+)
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspTextDocumentItem struct {
+	URI     string `json:"uri"`
+	Version int    `json:"version"`
+	Text    string `json:"text"`
+}
+
+type lspTextDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type lspTextDocumentPositionParams struct {
+	TextDocument lspTextDocumentIdentifier `json:"textDocument"`
+	Position     lspPosition               `json:"position"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Code     string   `json:"code,omitempty"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspDocumentSymbol struct {
+	Name           string              `json:"name"`
+	Detail         string              `json:"detail,omitempty"`
+	Kind           int                 `json:"kind"`
+	Range          lspRange            `json:"range"`
+	SelectionRange lspRange            `json:"selectionRange"`
+	Children       []lspDocumentSymbol `json:"children,omitempty"`
+}
+
+type lspCompletionItem struct {
+	Label  string `json:"label"`
+	Kind   int    `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// severities, symbol kinds and completion kinds from the specification
+const (
+	lspSeverityError   = 1
+	lspSeverityWarning = 2
+
+	lspSymbolModule     = 2
+	lspSymbolEnum       = 10
+	lspSymbolFunction   = 12
+	lspSymbolVariable   = 13
+	lspSymbolEnumMember = 22
+
+	lspCompletionFunction   = 3
+	lspCompletionVariable   = 6
+	lspCompletionModule     = 9
+	lspCompletionEnum       = 13
+	lspCompletionKeyword    = 14
+	lspCompletionEnumMember = 20
+)
+
+// semantic token legend sent in the initialize result, the index is the token type
+var lspTokenTypes = []string{"keyword", "type", "function", "parameter", "variable", "string", "number", "operator", "comment", "namespace", "enum", "enumMember"}
+var lspTokenModifiers = []string{"declaration"}
+
+const (
+	semKeyword = iota
+	semType
+	semFunction
+	semParameter
+	semVariable
+	semString
+	semNumber
+	semOperator
+	semComment
+	semNamespace
+	semEnum
+	semEnumMember
+)
+
+type lspServer struct {
+	reader   *bufio.Reader
+	writer   io.Writer
+	docs     map[string]*lspDocument
+	shutdown bool
+}
+
+// ServeLSP runs the frog language server over in/out (stdin/stdout for `frog lsp`)
+// until the client sends exit or closes the input
+func ServeLSP(in io.Reader, out io.Writer) error {
+	s := &lspServer{
+		reader: bufio.NewReader(in),
+		writer: out,
+		docs:   make(map[string]*lspDocument),
+	}
+	for {
+		body, err := readMessage(s.reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req rpcRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			s.reply(json.RawMessage("null"), nil, &rpcError{Code: rpcParseError, Message: err.Error()})
+			continue
+		}
+		if req.Method == "exit" {
+			return nil
+		}
+
+		result, rerr := s.safeHandle(&req)
+		if len(req.ID) == 0 {
+			// notification, no answer
+			continue
+		}
+		if err := s.reply(req.ID, result, rerr); err != nil {
+			return err
+		}
+	}
+}
+
+// safeHandle keeps the server alive when a request hits a bug,
+// the editor gets an error for it instead of losing the server
+func (s *lspServer) safeHandle(req *rpcRequest) (result interface{}, rerr *rpcError) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = nil
+			rerr = &rpcError{Code: rpcInternalError, Message: fmt.Sprintf("%s failed: %v", req.Method, r)}
+		}
+	}()
+	return s.handle(req)
+}
+
+func (s *lspServer) reply(id json.RawMessage, result interface{}, rerr *rpcError) error {
+	if rerr != nil {
+		return writeMessage(s.writer, rpcErrorResponse{JSONRPC: "2.0", ID: id, Error: rerr})
+	}
+	return writeMessage(s.writer, rpcResponse{JSONRPC: "2.0", ID: id, Result: result})
+}
+
+func (s *lspServer) notify(method string, params interface{}) error {
+	return writeMessage(s.writer, rpcNotification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (s *lspServer) handle(req *rpcRequest) (interface{}, *rpcError) {
+	if s.shutdown && req.Method != "exit" {
+		return nil, &rpcError{Code: rpcInvalidRequest, Message: "server is shutting down"}
+	}
+
+	switch req.Method {
+	case "initialize":
+		return s.initialize(), nil
+	case "initialized", "$/cancelRequest", "$/setTrace", "workspace/didChangeConfiguration":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params struct {
+			TextDocument lspTextDocumentItem `json:"textDocument"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		s.update(params.TextDocument.URI, params.TextDocument.Text)
+		return nil, nil
+	case "textDocument/didChange":
+		var params struct {
+			TextDocument   lspTextDocumentIdentifier `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		// full synchronization : the last change holds the whole text
+		if n := len(params.ContentChanges); n > 0 {
+			s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params struct {
+			TextDocument lspTextDocumentIdentifier `json:"textDocument"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		delete(s.docs, params.TextDocument.URI)
+		s.notify("textDocument/publishDiagnostics", map[string]interface{}{
+			"uri":         params.TextDocument.URI,
+			"diagnostics": []lspDiagnostic{},
+		})
+		return nil, nil
+	case "textDocument/hover":
+		return s.withPosition(req, s.hover)
+	case "textDocument/definition":
+		return s.withPosition(req, s.definition)
+	case "textDocument/completion":
+		return s.withPosition(req, s.completion)
+	case "textDocument/documentSymbol":
+		doc, rerr := s.document(req)
+		if rerr != nil {
+			return nil, rerr
+		}
+		return documentSymbols(doc), nil
+	case "textDocument/semanticTokens/full":
+		doc, rerr := s.document(req)
+		if rerr != nil {
+			return nil, rerr
+		}
+		return map[string]interface{}{"data": semanticTokens(doc)}, nil
+	}
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: "method not found: " + req.Method}
+}
+
+func invalidParams(err error) *rpcError {
+	return &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+}
+
+func (s *lspServer) initialize() interface{} {
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			"textDocumentSync":       1, // full
+			"hoverProvider":          true,
+			"definitionProvider":     true,
+			"documentSymbolProvider": true,
+			"completionProvider":     map[string]interface{}{"triggerCharacters": []string{"."}},
+			"semanticTokensProvider": map[string]interface{}{
+				"legend": map[string]interface{}{
+					"tokenTypes":     lspTokenTypes,
+					"tokenModifiers": lspTokenModifiers,
+				},
+				"full": true,
+			},
+		},
+		"serverInfo": map[string]interface{}{"name": "frog"},
+	}
+}
+
+// update re-analyzes a document and publishes its diagnostics
+func (s *lspServer) update(uri, text string) {
+	doc := analyzeDocument(uri, uriToPath(uri), text, make(map[string]bool))
+	s.docs[uri] = doc
+	s.notify("textDocument/publishDiagnostics", map[string]interface{}{
+		"uri":         uri,
+		"diagnostics": diagnostics(doc),
+	})
+}
+
+func (s *lspServer) document(req *rpcRequest) (*lspDocument, *rpcError) {
+	var params struct {
+		TextDocument lspTextDocumentIdentifier `json:"textDocument"`
+	}
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return nil, invalidParams(err)
+	}
+	doc, ok := s.docs[params.TextDocument.URI]
+	if !ok {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: "unknown document: " + params.TextDocument.URI}
+	}
+	return doc, nil
+}
+
+// withPosition decodes TextDocumentPositionParams and converts the position to frog line/column
+func (s *lspServer) withPosition(req *rpcRequest, fn func(doc *lspDocument, line, col int) interface{}) (interface{}, *rpcError) {
+	var params lspTextDocumentPositionParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return nil, invalidParams(err)
+	}
+	doc, ok := s.docs[params.TextDocument.URI]
+	if !ok {
+		return nil, &rpcError{Code: rpcInvalidParams, Message: "unknown document: " + params.TextDocument.URI}
+	}
+	line := params.Position.Line + 1
+	return fn(doc, line, doc.fromUTF16(line, params.Position.Character)), nil
+}
+
+// tokenRange is the editor range covering a frog token
+func (doc *lspDocument) tokenRange(tok Token) lspRange {
+	start := doc.toUTF16(tok.Line, tok.Column)
+	end := doc.toUTF16(tok.Line, tok.Column+doc.tokenLength(tok))
+	return lspRange{
+		Start: lspPosition{Line: tok.Line - 1, Character: start},
+		End:   lspPosition{Line: tok.Line - 1, Character: end},
+	}
+}
+
+func diagnostics(doc *lspDocument) []lspDiagnostic {
+	out := []lspDiagnostic{}
+	for _, d := range doc.errors {
+		out = append(out, doc.toLSPDiagnostic(d, "frog"))
+	}
+	if len(doc.errors) > 0 {
+		// the AST is incomplete, lint would only add noise
+		return out
+	}
+	for _, d := range NewLinter().Lint(doc.program, doc.comments) {
+		out = append(out, doc.toLSPDiagnostic(d, "frog lint"))
+	}
+	return out
+}
+
+func (doc *lspDocument) toLSPDiagnostic(d Diagnostic, source string) lspDiagnostic {
+	severity := lspSeverityError
+	if d.Severity == SeverityWarning {
+		severity = lspSeverityWarning
+	}
+	message := d.Message
+	if d.File != doc.Path {
+		// error inside a FRG_Use file, show it at the top of this one
+		message = d.String()
+		d.Line, d.Column, d.EndLine, d.EndColumn = 1, 1, 1, 1
+	}
+	return lspDiagnostic{
+		Range: lspRange{
+			Start: lspPosition{Line: d.Line - 1, Character: doc.toUTF16(d.Line, d.Column)},
+			End:   lspPosition{Line: d.EndLine - 1, Character: doc.toUTF16(d.EndLine, d.EndColumn)},
+		},
+		Severity: severity,
+		Code:     d.Code,
+		Source:   source,
+		Message:  message,
+	}
+}
+
+func (s *lspServer) hover(doc *lspDocument, line, col int) interface{} {
+	tok, ok := doc.tokenAt(line, col)
+	if !ok || tok.Type != TokenIdentifier {
+		return nil
+	}
+	sym := doc.refs[tokenKey(tok)]
+	if sym == nil {
+		return nil
+	}
+	text := "```frog\n"
+	if sym.Kind == declParameter {
+		text += "(parameter) "
+	}
+	text += sym.Detail + "\n```"
+	if sym.Doc != doc {
+		text += fmt.Sprintf("\n\ndeclared in `%s` line %d", sym.Doc.Path, sym.Token.Line)
+	}
+	return map[string]interface{}{
+		"contents": map[string]interface{}{"kind": "markdown", "value": text},
+		"range":    doc.tokenRange(tok),
+	}
+}
+
+func (s *lspServer) definition(doc *lspDocument, line, col int) interface{} {
+	tok, ok := doc.tokenAt(line, col)
+	if !ok || tok.Type != TokenIdentifier {
+		return nil
+	}
+	sym := doc.refs[tokenKey(tok)]
+	if sym == nil {
+		return nil
+	}
+	return lspLocation{URI: sym.Doc.URI, Range: sym.Doc.tokenRange(sym.Token)}
+}
+
+func (s *lspServer) completion(doc *lspDocument, line, col int) interface{} {
+	items := []lspCompletionItem{}
+	if before := doc.symbolBefore(line, col); before != nil {
+		if module := before.Module; module != nil {
+			for _, sym := range module.global.order {
+				if module.exports[sym.Name] {
+					items = append(items, lspCompletionItem{Label: sym.Name, Kind: completionKind(sym), Detail: sym.Detail})
+				}
+			}
+		}
+		for _, sym := range before.Members {
+			items = append(items, lspCompletionItem{Label: sym.Name, Kind: completionKind(sym), Detail: sym.Detail})
+		}
+		return items
+	}
+	for _, sym := range doc.visible(line, col) {
+		items = append(items, lspCompletionItem{Label: sym.Name, Kind: completionKind(sym), Detail: sym.Detail})
+	}
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	for _, word := range words {
+		items = append(items, lspCompletionItem{Label: word, Kind: lspCompletionKeyword})
+	}
+	return items
+}
+
+func completionKind(sym *lspSymbol) int {
+	switch sym.Kind {
+	case declFunction:
+		return lspCompletionFunction
+	case declModule:
+		return lspCompletionModule
+	case declEnum:
+		return lspCompletionEnum
+	case declEnumMember:
+		return lspCompletionEnumMember
+	}
+	return lspCompletionVariable
+}
+
+func documentSymbols(doc *lspDocument) []lspDocumentSymbol {
+	out := []lspDocumentSymbol{}
+	for _, sym := range doc.global.order {
+		if sym.Doc != doc {
+			continue // imported with FRG_Use ... From
+		}
+		ds := lspDocumentSymbol{
+			Name:           sym.Name,
+			Detail:         sym.Detail,
+			Kind:           lspSymbolVariable,
+			Range:          doc.tokenRange(sym.Token),
+			SelectionRange: doc.tokenRange(sym.Token),
+		}
+		if sym.Kind == declModule {
+			ds.Kind = lspSymbolModule
+		}
+		if sym.Kind == declEnum {
+			ds.Kind = lspSymbolEnum
+			for _, member := range sym.Members {
+				ds.Children = append(ds.Children, lspDocumentSymbol{
+					Name:           member.Name,
+					Detail:         member.Detail,
+					Kind:           lspSymbolEnumMember,
+					Range:          doc.tokenRange(member.Token),
+					SelectionRange: doc.tokenRange(member.Token),
+				})
+			}
+		}
+		if fn, ok := doc.funcByAST[sym.Func]; ok {
+			ds.Kind = lspSymbolFunction
+			ds.Range = lspRange{
+				Start: doc.tokenRange(fn.decl.Token).Start,
+				End:   doc.tokenRange(fn.end).End,
+			}
+			for _, local := range fn.scope.order {
+				ds.Children = append(ds.Children, lspDocumentSymbol{
+					Name:           local.Name,
+					Detail:         local.Detail,
+					Kind:           lspSymbolVariable,
+					Range:          doc.tokenRange(local.Token),
+					SelectionRange: doc.tokenRange(local.Token),
+				})
+			}
+		}
+		out = append(out, ds)
+	}
+	return out
+}
+
+// semanticTokens encodes the tokens of the document as the specification wants:
+// 5 integers per token (delta line, delta start, length, type, modifiers)
+func semanticTokens(doc *lspDocument) []int {
+	type entry struct {
+		tok       Token
+		typ       int
+		modifiers int
+	}
+	entries := []entry{}
+	for i, tok := range doc.tokens {
+		typ := -1
+		switch tok.Type {
+		case TokenEOF, TokenIllegal:
+		case TokenIdentifier:
+			typ = semVariable
+			if sym := doc.refs[tokenKey(tok)]; sym != nil {
+				switch sym.Kind {
+				case declFunction:
+					typ = semFunction
+				case declParameter:
+					typ = semParameter
+				case declModule:
+					typ = semNamespace
+				case declEnum:
+					typ = semEnum
+				case declEnumMember:
+					typ = semEnumMember
+				}
+			} else if i+1 < len(doc.tokens) && doc.tokens[i+1].Type == TokenLParen {
+				typ = semFunction
+			}
+		case TokenFRGInt, TokenFRGReal, TokenFRGStrg, TokenFRGVoid:
+			typ = semType
+		case TokenString:
+			typ = semString
+		case TokenNumber:
+			typ = semNumber
+		default:
+			if _, ok := keywords[tok.Literal]; ok {
+				typ = semKeyword
+			} else if _, ok := precedences[tok.Type]; ok || tok.Type == TokenAssign {
+				typ = semOperator
+			}
+		}
+		if typ < 0 {
+			continue
+		}
+		modifiers := 0
+		if tok.Type == TokenIdentifier && doc.decls[tokenKey(tok)] {
+			modifiers = 1 // declaration
+		}
+		entries = append(entries, entry{tok: tok, typ: typ, modifiers: modifiers})
+	}
+	for _, c := range doc.comments {
+		entries = append(entries, entry{tok: c, typ: semComment})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i].tok, entries[j].tok
+		return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+	})
+
+	data := []int{}
+	prevLine, prevStart := 0, 0
+	for _, e := range entries {
+		r := doc.tokenRange(e.tok)
+		line, start := r.Start.Line, r.Start.Character
+		length := r.End.Character - r.Start.Character
+		if length <= 0 || r.End.Line != line {
+			continue
+		}
+		deltaStart := start
+		if line == prevLine {
+			deltaStart = start - prevStart
+		}
+		data = append(data, line-prevLine, deltaStart, length, e.typ, e.modifiers)
+		prevLine, prevStart = line, start
+	}
+	return data
+}
@@ -0,0 +1,518 @@
+// Copyright (C) by abdenour souane
+// you have a right to modify it upgrade it or do whatever you want
+// but u have to keep my name on it
+
+package frog
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// the language server keeps one lspDocument per open file (and per FRG_Use module it follows)
+// with everything needed to answer the editor: tokens, AST, declared symbols and
+// which declaration every identifier refers to
+
+// lspSymbol is something declared in a frog file
+type lspSymbol struct {
+	Name    string
+	Kind    declKind
+	Detail  string // how it was declared, e.g. "FRG_Int[] xs"
+	Token   Token  // the declaring identifier
+	Doc     *lspDocument
+	Func    *FunctionDeclarationStatement // set for declFunction
+	Module  *lspDocument                  // set for declModule, nil when the file was not found
+	Members []*lspSymbol                  // set for declEnum
+}
+
+// member finds the member of an enum symbol, nil if there is none
+func (sym *lspSymbol) member(name string) *lspSymbol {
+	for _, m := range sym.Members {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+type lspScope struct {
+	parent   *lspScope
+	symbols  map[string]*lspSymbol
+	order    []*lspSymbol
+	declared map[string]Token // where the names are declared in this document
+}
+
+func newLspScope(parent *lspScope) *lspScope {
+	return &lspScope{parent: parent, symbols: make(map[string]*lspSymbol), declared: make(map[string]Token)}
+}
+
+// lspFunction is the scope of a FRG_Fn body
+type lspFunction struct {
+	decl  *FunctionDeclarationStatement
+	scope *lspScope
+	end   Token // the End closing the body
+}
+
+type lspDocument struct {
+	URI  string
+	Path string
+	Text string
+
+	lines    []string
+	tokens   []Token
+	comments []Token
+	program  *Program
+	errors   []Diagnostic
+
+	global    *lspScope
+	funcs     []*lspFunction
+	funcByAST map[*FunctionDeclarationStatement]*lspFunction
+	refs      map[[2]int]*lspSymbol // identifier (line, col) -> declaration
+	decls     map[[2]int]bool       // identifiers that declare something
+	modules   map[*UseStatement]*lspDocument
+	exports   map[string]bool // names declared with FRG_Export
+}
+
+func tokenKey(t Token) [2]int {
+	return [2]int{t.Line, t.Column}
+}
+
+// analyzeDocument lexes, parses and indexes a frog source
+// chain holds the files being analyzed above this one to stop FRG_Use cycles
+func analyzeDocument(uri, path, text string, chain map[string]bool) *lspDocument {
+	doc := &lspDocument{
+		URI:       uri,
+		Path:      path,
+		Text:      text,
+		lines:     strings.Split(text, "\n"),
+		funcByAST: make(map[*FunctionDeclarationStatement]*lspFunction),
+		refs:      make(map[[2]int]*lspSymbol),
+		decls:     make(map[[2]int]bool),
+		modules:   make(map[*UseStatement]*lspDocument),
+		exports:   make(map[string]bool),
+	}
+
+	lexer := NewFileLexer(path, text)
+	parser := NewParser(lexer)
+	doc.program = parser.ParseProgram()
+	doc.errors = parser.Diagnostics()
+	doc.comments = lexer.Comments()
+	doc.tokens = NewFileLexer(path, text).GetAllTokens()
+
+	if path != "" {
+		chain[path] = true
+		defer delete(chain, path)
+	}
+	for _, stmt := range doc.program.Statements {
+		if es, ok := stmt.(*ExportStatement); ok {
+			for _, name := range es.Names() {
+				doc.exports[name.Value] = true
+			}
+		}
+	}
+	loader := newModuleLoader()
+	inspect(doc.program, func(n Node) bool {
+		us, ok := n.(*UseStatement)
+		if !ok {
+			return true
+		}
+		if us.Filename == nil {
+			return false
+		}
+		modulePath, _, err := loader.resolve(path, us.Filename.Value)
+		if err != nil || chain[modulePath] {
+			return false
+		}
+		content, err := ReadSource(modulePath)
+		if err != nil {
+			return false
+		}
+		doc.modules[us] = analyzeDocument(pathToURI(modulePath), modulePath, string(content), chain)
+		return false
+	})
+
+	doc.global = newLspScope(nil)
+	// declarations first so a function can be used above the place it is declared
+	doc.declareIn(doc.program, doc.global)
+	doc.resolveIn(doc.program, doc.global)
+	return doc
+}
+
+func pathToURI(path string) string {
+	if name, ok := strings.CutPrefix(path, StdPrefix); ok {
+		// a standard module, it only exists in the binary
+		return "frog-std:///" + name
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+func (doc *lspDocument) add(scope *lspScope, ident *Identifier, kind declKind, detail string, fn *FunctionDeclarationStatement) *lspSymbol {
+	sym := &lspSymbol{Name: ident.Value, Kind: kind, Detail: detail, Token: ident.Token, Doc: doc, Func: fn}
+	doc.bind(scope, ident, sym)
+	return sym
+}
+
+// bind makes ident declare sym in scope, sym may come from another document
+func (doc *lspDocument) bind(scope *lspScope, ident *Identifier, sym *lspSymbol) {
+	if _, ok := scope.symbols[ident.Value]; !ok {
+		scope.symbols[ident.Value] = sym
+		scope.order = append(scope.order, sym)
+		scope.declared[ident.Value] = ident.Token
+	}
+	doc.refs[tokenKey(ident.Token)] = sym
+	doc.decls[tokenKey(ident.Token)] = true
+}
+
+// export returns the symbol name exported by the module, nil if there is none
+func (doc *lspDocument) export(name string) *lspSymbol {
+	if doc == nil || !doc.exports[name] {
+		return nil
+	}
+	return doc.global.symbols[name]
+}
+
+func (doc *lspDocument) declareIn(node Node, scope *lspScope) {
+	inspect(node, func(n Node) bool {
+		switch n := n.(type) {
+		case *UseStatement:
+			// the module is indexed on its own (doc.modules)
+			module := doc.modules[n]
+			if n.Alias != nil {
+				sym := doc.add(scope, n.Alias, declModule, n.String(), nil)
+				sym.Module = module
+			}
+			for _, name := range n.Names {
+				if sym := module.export(name.Value); sym != nil {
+					doc.bind(scope, name, sym)
+				} else {
+					doc.add(scope, name, declVariable, n.String(), nil)
+				}
+			}
+			return false
+		case *DeclarationStatement:
+			for i, ident := range n.Identifiers {
+				doc.add(scope, ident, declVariable, declarationDetail(n, ident), nil)
+				if value := n.value(i); value != nil {
+					doc.declareIn(value, scope)
+				}
+			}
+			return false
+		case *ConstStatement:
+			for i, name := range n.Names {
+				doc.add(scope, name, declVariable, n.Token.Literal+" "+typeString(n.Type, n.IsArray)+" "+name.Value+" := "+n.Values[i].String(), nil)
+				doc.declareIn(n.Values[i], scope)
+			}
+			return false
+		case *EnumStatement:
+			sym := doc.add(scope, n.Name, declEnum, n.String(), nil)
+			for i, member := range n.Members {
+				// the members are not names of the scope, only Color.Red reaches them
+				m := &lspSymbol{Name: member.Value, Kind: declEnumMember, Detail: fmt.Sprintf("%s.%s = %d", n.Name.Value, member.Value, i), Token: member.Token, Doc: doc}
+				sym.Members = append(sym.Members, m)
+				doc.refs[tokenKey(member.Token)] = m
+				doc.decls[tokenKey(member.Token)] = true
+			}
+			return false
+		case *FunctionDeclarationStatement:
+			if n.Name == nil {
+				return false
+			}
+			if !n.Anonymous() {
+				doc.add(scope, n.Name, declFunction, functionSignature(n), n)
+			}
+			fn := &lspFunction{decl: n, scope: newLspScope(scope), end: doc.blockEnd(n.Body)}
+			doc.funcs = append(doc.funcs, fn)
+			doc.funcByAST[n] = fn
+			for _, param := range n.Parameters {
+				if param != nil && param.Name != nil {
+					doc.add(fn.scope, param.Name, declParameter, param.String(), nil)
+				}
+			}
+			if n.Body != nil {
+				doc.declareIn(n.Body, fn.scope)
+			}
+			return false
+		}
+		return true
+	})
+}
+
+func (doc *lspDocument) resolveIn(node Node, scope *lspScope) {
+	inspect(node, func(n Node) bool {
+		switch n := n.(type) {
+		case *UseStatement:
+			return false
+		case *MemberExpression:
+			doc.resolveIn(n.Object, scope)
+			var object Token
+			switch o := n.Object.(type) {
+			case *Identifier:
+				object = o.Token
+			case *MemberExpression:
+				// std.Color.Red
+				if o.Member == nil {
+					return false
+				}
+				object = o.Member.Token
+			default:
+				return false
+			}
+			if n.Member == nil {
+				return false
+			}
+			sym := doc.refs[tokenKey(object)]
+			var member *lspSymbol
+			switch {
+			case sym == nil:
+			case sym.Kind == declModule:
+				member = sym.Module.export(n.Member.Value)
+			case sym.Kind == declEnum:
+				member = sym.member(n.Member.Value)
+			}
+			if member != nil {
+				doc.refs[tokenKey(n.Member.Token)] = member
+			}
+			return false
+		case *FunctionDeclarationStatement:
+			fn, ok := doc.funcByAST[n]
+			if !ok {
+				return false
+			}
+			for _, param := range n.Parameters {
+				if param != nil && param.Default != nil {
+					doc.resolveIn(param.Default, fn.scope)
+				}
+			}
+			if n.Body != nil {
+				doc.resolveIn(n.Body, fn.scope)
+			}
+			return false
+		case *Identifier:
+			key := tokenKey(n.Token)
+			if doc.decls[key] {
+				return true
+			}
+			if sym := doc.lookup(n.Value, scope); sym != nil {
+				doc.refs[key] = sym
+			}
+		}
+		return true
+	})
+}
+
+// lookup searches the scope chain, the names of a module are reached through it
+func (doc *lspDocument) lookup(name string, scope *lspScope) *lspSymbol {
+	for s := scope; s != nil; s = s.parent {
+		if sym, ok := s.symbols[name]; ok {
+			return sym
+		}
+	}
+	return nil
+}
+
+// visible returns every name that can be used at the frog position, nearest first
+// the names of its own scope must be declared above it, a FRG_Fn body runs
+// when it is called so it can use every name of the scopes around it
+func (doc *lspDocument) visible(line, col int) []*lspSymbol {
+	seen := make(map[string]bool)
+	var out []*lspSymbol
+	scope := doc.scopeAt(line, col)
+	for s := scope; s != nil; s = s.parent {
+		for _, sym := range s.order {
+			at := s.declared[sym.Name]
+			if s == scope && positionBefore(line, col, at.Line, at.Column+utf8.RuneCountInString(at.Literal)) {
+				continue
+			}
+			if !seen[sym.Name] {
+				seen[sym.Name] = true
+				out = append(out, sym)
+			}
+		}
+	}
+	return out
+}
+
+// symbolBefore returns the module or the enum named just before the dot ending
+// at the frog position, "std." completes with the exports of std
+func (doc *lspDocument) symbolBefore(line, col int) *lspSymbol {
+	for i, tok := range doc.tokens {
+		if tok.Type != TokenDot || tok.Line != line || tok.Column+1 != col || i == 0 {
+			continue
+		}
+		// the line being typed may not parse, look the name up then
+		sym := doc.refs[tokenKey(doc.tokens[i-1])]
+		if sym == nil {
+			sym = doc.lookup(doc.tokens[i-1].Literal, doc.scopeAt(line, col))
+		}
+		if sym != nil && (sym.Kind == declModule || sym.Kind == declEnum) {
+			return sym
+		}
+	}
+	return nil
+}
+
+// blockEnd finds the End token closing the Begin of block
+func (doc *lspDocument) blockEnd(block *BlockStatement) Token {
+	if block == nil {
+		return Token{}
+	}
+	depth := 0
+	started := false
+	for _, tok := range doc.tokens {
+		if !started {
+			if tok.Line == block.Token.Line && tok.Column == block.Token.Column {
+				started = true
+			} else {
+				continue
+			}
+		}
+		switch tok.Type {
+		case TokenHash:
+			// the body of FRG_Fn(...) : T := expr stops with the statement
+			if depth == 0 {
+				return tok
+			}
+		case TokenBegin:
+			depth++
+		case TokenEnd:
+			depth--
+			if depth == 0 {
+				return tok
+			}
+		}
+	}
+	if len(doc.tokens) > 0 {
+		return doc.tokens[len(doc.tokens)-1]
+	}
+	return Token{}
+}
+
+// scopeAt returns the innermost scope containing the frog position (1-based)
+func (doc *lspDocument) scopeAt(line, col int) *lspScope {
+	var best *lspFunction
+	for _, fn := range doc.funcs {
+		// the cursor just before FRG_Fn is not in the function yet
+		if !positionBefore(line, col, fn.decl.Token.Line, fn.decl.Token.Column) &&
+			positionBefore(line, col, fn.end.Line, fn.end.Column+len(fn.end.Literal)) {
+			if best == nil || positionBefore(best.decl.Token.Line, best.decl.Token.Column, fn.decl.Token.Line, fn.decl.Token.Column) {
+				best = fn
+			}
+		}
+	}
+	if best != nil {
+		return best.scope
+	}
+	return doc.global
+}
+
+func positionBefore(l1, c1, l2, c2 int) bool {
+	return l1 < l2 || (l1 == l2 && c1 <= c2)
+}
+
+// tokenAt returns the token under the frog position (1-based line and rune column),
+// between two tokens the identifier wins: the cursor of a.|b is on b, the one of b|# too
+func (doc *lspDocument) tokenAt(line, col int) (Token, bool) {
+	var found *Token
+	for i, tok := range doc.tokens {
+		if tok.Line == line && tok.Column <= col && col <= tok.Column+doc.tokenLength(tok) {
+			if tok.Type == TokenEOF {
+				break
+			}
+			if found == nil || (tok.Type == TokenIdentifier && found.Type != TokenIdentifier) {
+				found = &doc.tokens[i]
+			}
+		}
+	}
+	if found == nil {
+		return Token{}, false
+	}
+	return *found, true
+}
+
+// tokenLength is the length of the token in runes as written in the source
+func (doc *lspDocument) tokenLength(tok Token) int {
+	if tok.Type != TokenString {
+		return utf8.RuneCountInString(tok.Literal)
+	}
+	// the literal lost its quotes and escapes, measure the source instead
+	if tok.Line < 1 || tok.Line > len(doc.lines) {
+		return utf8.RuneCountInString(tok.Literal) + 2
+	}
+	runes := []rune(doc.lines[tok.Line-1])
+	i := tok.Column // just after the opening quote
+	for i < len(runes) && runes[i] != '"' {
+		if runes[i] == '\\' {
+			i++
+		}
+		i++
+	}
+	if i >= len(runes) {
+		return len(runes) - tok.Column + 1
+	}
+	return i - tok.Column + 2
+}
+
+// frog columns count runes from 1, editors count UTF-16 units from 0
+
+func (doc *lspDocument) toUTF16(line, col int) int {
+	if line < 1 || line > len(doc.lines) {
+		return col - 1
+	}
+	return utf16Offset(doc.lines[line-1], col)
+}
+
+// utf16Offset converts the frog column col of text to a 0-based UTF-16 offset
+func utf16Offset(text string, col int) int {
+	runes := []rune(text)
+	if col-1 > len(runes) {
+		return len(utf16.Encode(runes)) + col - 1 - len(runes)
+	}
+	if col < 1 {
+		return 0
+	}
+	return len(utf16.Encode(runes[:col-1]))
+}
+
+func (doc *lspDocument) fromUTF16(line, character int) int {
+	if line < 1 || line > len(doc.lines) {
+		return character + 1
+	}
+	units := 0
+	for i, r := range []rune(doc.lines[line-1]) {
+		if units >= character {
+			return i + 1
+		}
+		units += len(utf16.Encode([]rune{r}))
+	}
+	return utf8.RuneCountInString(doc.lines[line-1]) + 1
+}
+
+func declarationDetail(ds *DeclarationStatement, ident *Identifier) string {
+	typ := ds.Token.Literal
+	if ds.IsArray {
+		typ += "[]"
+	}
+	return typ + " " + ident.Value
+}
+
+func functionSignature(fn *FunctionDeclarationStatement) string {
+	params := []string{}
+	for _, param := range fn.Parameters {
+		if param != nil && param.Name != nil {
+			params = append(params, param.String())
+		}
+	}
+	return "FRG_Fn " + fn.Name.Value + "(" + strings.Join(params, ", ") + ") : " + typeString(fn.ReturnType, fn.ReturnsArray)
+}
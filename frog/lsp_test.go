@@ -0,0 +1,329 @@
+// Copyright (C) by abdenour souane
+// you have a right to modify it upgrade it or do whatever you want
+// but u have to keep my name on it
+
+package frog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// lspSession sends the messages to a server and returns everything it wrote back
+func lspSession(t *testing.T, messages ...interface{}) []map[string]interface{} {
+	var in bytes.Buffer
+	for _, m := range messages {
+		if err := writeMessage(&in, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var out bytes.Buffer
+	if err := ServeLSP(&in, &out); err != nil {
+		t.Fatalf("the server stopped: %v", err)
+	}
+	replies := []map[string]interface{}{}
+	reader := bufio.NewReader(&out)
+	for {
+		body, err := readMessage(reader)
+		if err == io.EOF {
+			return replies
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		var reply map[string]interface{}
+		if err := json.Unmarshal(body, &reply); err != nil {
+			t.Fatal(err)
+		}
+		replies = append(replies, reply)
+	}
+}
+
+func lspRequest(id int, method string, params interface{}) map[string]interface{} {
+	return map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params}
+}
+
+// a half typed string ending with a backslash used to crash the lexer
+func TestLSPUnterminatedString(t *testing.T) {
+	uri := "file:///tmp/typing.frg"
+	text := "FRG_Begin\n    FRG_Print \"a\\"
+	replies := lspSession(t,
+		map[string]interface{}{"jsonrpc": "2.0", "method": "textDocument/didOpen", "params": map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri, "languageId": "frog", "version": 1, "text": text},
+		}},
+		lspRequest(1, "textDocument/hover", map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri},
+			"position":     map[string]interface{}{"line": 1, "character": 15},
+		}),
+	)
+	if len(replies) != 2 {
+		t.Fatalf("got %d messages, want the diagnostics and the hover answer", len(replies))
+	}
+	diagnostics := replies[0]["params"].(map[string]interface{})["diagnostics"].([]interface{})
+	if len(diagnostics) == 0 || diagnostics[0].(map[string]interface{})["code"] != "unterminated-string" {
+		t.Errorf("want an unterminated-string diagnostic first, got %v", diagnostics)
+	}
+	if replies[1]["error"] != nil {
+		t.Errorf("hover failed: %v", replies[1]["error"])
+	}
+}
+
+// a request that panics gets an error answer, the next ones still work
+func TestLSPRecover(t *testing.T) {
+	s := &lspServer{docs: map[string]*lspDocument{"file:///broken.frg": nil}}
+	params, _ := json.Marshal(map[string]interface{}{"textDocument": map[string]interface{}{"uri": "file:///broken.frg"}})
+	result, rerr := s.safeHandle(&rpcRequest{Method: "textDocument/documentSymbol", Params: params})
+	if rerr == nil || rerr.Code != rpcInternalError || !strings.Contains(rerr.Message, "documentSymbol") {
+		t.Fatalf("want an internal error, got %v %v", result, rerr)
+	}
+	if _, rerr := s.safeHandle(&rpcRequest{Method: "shutdown"}); rerr != nil {
+		t.Errorf("shutdown after the panic: %v", rerr)
+	}
+}
+
+const lspSource = `FRG_Begin
+    FRG_Enum Color Begin Red, Green End
+    FRG_Fn double(FRG_Int x) : FRG_Int
+    Begin
+        FRG_Int y#
+        y := x * 2#
+        double := y#
+    End
+    FRG_Int a#
+    a := double(3)#
+    FRG_Print Color.Green, "\n"#
+FRG_End
+`
+
+func lspOpen(uri, text string) map[string]interface{} {
+	return map[string]interface{}{"jsonrpc": "2.0", "method": "textDocument/didOpen", "params": map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "languageId": "frog", "version": 1, "text": text},
+	}}
+}
+
+func lspAt(uri string, line, character int) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri},
+		"position":     map[string]interface{}{"line": line, "character": character},
+	}
+}
+
+// lspResults opens the document, sends the requests numbered from 1
+// and returns their results in the same order
+func lspResults(t *testing.T, uri, text string, requests ...map[string]interface{}) []interface{} {
+	t.Helper()
+	messages := []interface{}{lspOpen(uri, text)}
+	for _, r := range requests {
+		messages = append(messages, r)
+	}
+	results := make([]interface{}, len(requests))
+	for _, reply := range lspSession(t, messages...) {
+		id, ok := reply["id"].(float64)
+		if !ok {
+			continue // the diagnostics
+		}
+		if reply["error"] != nil {
+			t.Fatalf("request %v failed: %v", id, reply["error"])
+		}
+		results[int(id)-1] = reply["result"]
+	}
+	return results
+}
+
+// toJSON makes a result comparable with the expected json text
+func toJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// modulesDocument is tests/modules.frg opened from its file, its FRG_Use are followed
+func modulesDocument(t *testing.T) (string, string, string) {
+	t.Helper()
+	path, err := filepath.Abs(filepath.Join("..", "tests", "modules.frg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pathToURI(path), string(text), pathToURI(filepath.Join(filepath.Dir(path), "modules", "shapes.frg"))
+}
+
+func TestLSPHover(t *testing.T) {
+	uri := "file:///tmp/hover.frg"
+	results := lspResults(t, uri, lspSource,
+		lspRequest(1, "textDocument/hover", lspAt(uri, 9, 11)),  // double
+		lspRequest(2, "textDocument/hover", lspAt(uri, 5, 14)),  // x
+		lspRequest(3, "textDocument/hover", lspAt(uri, 10, 21)), // Green
+		lspRequest(4, "textDocument/hover", lspAt(uri, 9, 16)),  // 3
+	)
+	want := []string{
+		`{"contents":{"kind":"markdown","value":"` + "```frog\\nFRG_Fn double(FRG_Int x) : FRG_Int\\n```" + `"},"range":{"end":{"character":15,"line":9},"start":{"character":9,"line":9}}}`,
+		`{"contents":{"kind":"markdown","value":"` + "```frog\\n(parameter) FRG_Int x\\n```" + `"},"range":{"end":{"character":14,"line":5},"start":{"character":13,"line":5}}}`,
+		`{"contents":{"kind":"markdown","value":"` + "```frog\\nColor.Green = 1\\n```" + `"},"range":{"end":{"character":25,"line":10},"start":{"character":20,"line":10}}}`,
+		`null`,
+	}
+	for i, result := range results {
+		if got := toJSON(t, result); got != want[i] {
+			t.Errorf("hover %d: got\n%s\nwant\n%s", i+1, got, want[i])
+		}
+	}
+
+	// a name of a module says where it comes from
+	uri, text, shapes := modulesDocument(t)
+	results = lspResults(t, uri, text, lspRequest(1, "textDocument/hover", lspAt(uri, 7, 15)))
+	value := results[0].(map[string]interface{})["contents"].(map[string]interface{})["value"].(string)
+	path := uriToPath(shapes)
+	if want := "```frog\nFRG_Fn area(FRG_Int width, FRG_Int height) : FRG_Int\n```\n\ndeclared in `" + path + "` line 12"; value != want {
+		t.Errorf("got\n%s\nwant\n%s", value, want)
+	}
+}
+
+func TestLSPDefinition(t *testing.T) {
+	uri := "file:///tmp/definition.frg"
+	results := lspResults(t, uri, lspSource,
+		lspRequest(1, "textDocument/definition", lspAt(uri, 9, 12)), // double
+		lspRequest(2, "textDocument/definition", lspAt(uri, 6, 19)), // just after y, before the #
+		lspRequest(3, "textDocument/definition", lspAt(uri, 0, 3)),  // FRG_Begin
+	)
+	want := []string{
+		`{"range":{"end":{"character":17,"line":2},"start":{"character":11,"line":2}},"uri":"file:///tmp/definition.frg"}`,
+		`{"range":{"end":{"character":17,"line":4},"start":{"character":16,"line":4}},"uri":"file:///tmp/definition.frg"}`,
+		`null`,
+	}
+	for i, result := range results {
+		if got := toJSON(t, result); got != want[i] {
+			t.Errorf("definition %d: got\n%s\nwant\n%s", i+1, got, want[i])
+		}
+	}
+
+	// across FRG_Use: through the alias, through the module and with From
+	uri, text, shapes := modulesDocument(t)
+	results = lspResults(t, uri, text,
+		lspRequest(1, "textDocument/definition", lspAt(uri, 6, 25)), // geometry.perimeter
+		lspRequest(2, "textDocument/definition", lspAt(uri, 5, 21)), // just after the dot of shapes.area
+		lspRequest(3, "textDocument/definition", lspAt(uri, 7, 34)), // SIDES
+		lspRequest(4, "textDocument/definition", lspAt(uri, 6, 16)), // geometry
+	)
+	want = []string{
+		`{"range":{"end":{"character":31,"line":16},"start":{"character":22,"line":16}},"uri":"` + shapes + `"}`,
+		`{"range":{"end":{"character":26,"line":11},"start":{"character":22,"line":11}},"uri":"` + shapes + `"}`,
+		`{"range":{"end":{"character":28,"line":4},"start":{"character":23,"line":4}},"uri":"` + shapes + `"}`,
+		`{"range":{"end":{"character":44,"line":2},"start":{"character":36,"line":2}},"uri":"` + uri + `"}`,
+	}
+	for i, result := range results {
+		if got := toJSON(t, result); got != want[i] {
+			t.Errorf("definition across FRG_Use %d: got\n%s\nwant\n%s", i+1, got, want[i])
+		}
+	}
+}
+
+func TestLSPDocumentSymbols(t *testing.T) {
+	uri := "file:///tmp/symbols.frg"
+	results := lspResults(t, uri, lspSource,
+		lspRequest(1, "textDocument/documentSymbol", map[string]interface{}{"textDocument": map[string]interface{}{"uri": uri}}))
+	want := `[` +
+		`{"children":[` +
+		`{"detail":"Color.Red = 0","kind":22,"name":"Red","range":{"end":{"character":28,"line":1},"start":{"character":25,"line":1}},"selectionRange":{"end":{"character":28,"line":1},"start":{"character":25,"line":1}}},` +
+		`{"detail":"Color.Green = 1","kind":22,"name":"Green","range":{"end":{"character":35,"line":1},"start":{"character":30,"line":1}},"selectionRange":{"end":{"character":35,"line":1},"start":{"character":30,"line":1}}}],` +
+		`"detail":"FRG_Enum Color Begin Red, Green End","kind":10,"name":"Color","range":{"end":{"character":18,"line":1},"start":{"character":13,"line":1}},"selectionRange":{"end":{"character":18,"line":1},"start":{"character":13,"line":1}}},` +
+		`{"children":[` +
+		`{"detail":"FRG_Int x","kind":13,"name":"x","range":{"end":{"character":27,"line":2},"start":{"character":26,"line":2}},"selectionRange":{"end":{"character":27,"line":2},"start":{"character":26,"line":2}}},` +
+		`{"detail":"FRG_Int y","kind":13,"name":"y","range":{"end":{"character":17,"line":4},"start":{"character":16,"line":4}},"selectionRange":{"end":{"character":17,"line":4},"start":{"character":16,"line":4}}}],` +
+		`"detail":"FRG_Fn double(FRG_Int x) : FRG_Int","kind":12,"name":"double","range":{"end":{"character":7,"line":7},"start":{"character":4,"line":2}},"selectionRange":{"end":{"character":17,"line":2},"start":{"character":11,"line":2}}},` +
+		`{"detail":"FRG_Int a","kind":13,"name":"a","range":{"end":{"character":13,"line":8},"start":{"character":12,"line":8}},"selectionRange":{"end":{"character":13,"line":8},"start":{"character":12,"line":8}}}]`
+	if got := toJSON(t, results[0]); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+// completionLabels returns the labels of the items that are not keywords
+func completionLabels(result interface{}) []string {
+	labels := []string{}
+	for _, item := range result.([]interface{}) {
+		item := item.(map[string]interface{})
+		if item["kind"] != float64(lspCompletionKeyword) {
+			labels = append(labels, item["label"].(string))
+		}
+	}
+	return labels
+}
+
+func TestLSPCompletion(t *testing.T) {
+	uri := "file:///tmp/completion.frg"
+	results := lspResults(t, uri, lspSource,
+		lspRequest(1, "textDocument/completion", lspAt(uri, 5, 8)),   // in double, above y :=
+		lspRequest(2, "textDocument/completion", lspAt(uri, 4, 8)),   // in double, above FRG_Int y
+		lspRequest(3, "textDocument/completion", lspAt(uri, 2, 4)),   // just before FRG_Fn double
+		lspRequest(4, "textDocument/completion", lspAt(uri, 10, 4)),  // at the end
+		lspRequest(5, "textDocument/completion", lspAt(uri, 10, 20)), // after Color.
+	)
+	want := [][]string{
+		{"x", "y", "Color", "double", "a"},
+		{"x", "Color", "double", "a"},
+		{"Color"},
+		{"Color", "double", "a"},
+		{"Red", "Green"},
+	}
+	for i, result := range results {
+		if got := completionLabels(result); strings.Join(got, " ") != strings.Join(want[i], " ") {
+			t.Errorf("completion %d: got %v, want %v", i+1, got, want[i])
+		}
+	}
+	if items := results[3].([]interface{}); len(items) != 3+len(keywords) {
+		t.Errorf("got %d items, want the 3 names and the %d keywords", len(items), len(keywords))
+	}
+
+	// the names declared later in the file are not offered yet
+	uri, text, _ := modulesDocument(t)
+	results = lspResults(t, uri, text,
+		lspRequest(1, "textDocument/completion", lspAt(uri, 5, 4)),
+		lspRequest(2, "textDocument/completion", lspAt(uri, 12, 4)),
+		lspRequest(3, "textDocument/completion", lspAt(uri, 6, 23)), // after geometry.
+	)
+	want = [][]string{
+		{"shapes", "geometry", "area", "SIDES"},
+		{"shapes", "geometry", "area", "SIDES", "calls"},
+		{"SIDES", "area", "perimeter"},
+	}
+	for i, result := range results {
+		if got := completionLabels(result); strings.Join(got, " ") != strings.Join(want[i], " ") {
+			t.Errorf("completion in modules.frg %d: got %v, want %v", i+1, got, want[i])
+		}
+	}
+}
+
+func TestLSPSemanticTokens(t *testing.T) {
+	uri := "file:///tmp/tokens.frg"
+	text := "FRG_Begin\n    ## count\n    FRG_Int a#\n    a := len(\"ab\") + 1#\nFRG_End\n"
+	results := lspResults(t, uri, text,
+		lspRequest(1, "textDocument/semanticTokens/full", map[string]interface{}{"textDocument": map[string]interface{}{"uri": uri}}))
+	want := []int{
+		0, 0, 9, semKeyword, 0, // FRG_Begin
+		1, 4, 8, semComment, 0, // ## count
+		1, 4, 7, semType, 0, // FRG_Int
+		0, 8, 1, semVariable, 1, // a, declared
+		1, 4, 1, semVariable, 0, // a
+		0, 2, 2, semOperator, 0, // :=
+		0, 3, 3, semFunction, 0, // len
+		0, 3, 1, semOperator, 0, // (
+		0, 1, 4, semString, 0, // "ab"
+		0, 6, 1, semOperator, 0, // +
+		0, 2, 1, semNumber, 0, // 1
+		1, 0, 7, semKeyword, 0, // FRG_End
+	}
+	if got, want := toJSON(t, results[0].(map[string]interface{})["data"]), toJSON(t, want); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright (C) by abdenour souane
+// you have a right to modify it upgrade it or do whatever you want
+// but u have to keep my name on it
+
+package frog
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"frog_programming_language/std"
+)
+
+// FRG_Use "name"# finds its file in this order:
+//
+//	a standard module shipped in the binary ("std" is std/std.frg)
+//	the directory of the file using it
+//	a package required by the frog.mod of the project (see packages.go)
+//	every directory of the search path: the -I flags then $FRG_PATH
+//
+// ".frg" is added to a name without extension when the file has none
+
+// StdPrefix starts the path of the standard modules, they are not on the disk
+const StdPrefix = "std:"
+
+// SearchPathEnv holds directories where FRG_Use looks for files, separated like $PATH
+const SearchPathEnv = "FRG_PATH"
+
+// moduleLoader is shared by the parser of a program and the parsers of its modules
+type moduleLoader struct {
+	searchPath []string
+
+	// files already parsed (nil when they had errors), by key, each one is read once
+	programs map[string]*Program
+
+	// the files being parsed, the program first, to find the cycles
+	chain []moduleRef
+
+	// the project of every directory seen, nil outside of a project
+	projects map[string]*Project
+}
+
+type moduleRef struct {
+	key  string // absolute path, or StdPrefix + name
+	file string // as shown in the errors
+}
+
+func newModuleLoader() *moduleLoader {
+	return &moduleLoader{
+		searchPath: envSearchPath(),
+		programs:   make(map[string]*Program),
+		projects:   make(map[string]*Project),
+	}
+}
+
+func envSearchPath() []string {
+	dirs := []string{}
+	for _, dir := range filepath.SplitList(os.Getenv(SearchPathEnv)) {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// resolve returns the file (as shown in the errors) and the key of the module name used in from
+func (l *moduleLoader) resolve(from, name string) (file, key string, err error) {
+	if isStdModule(name) {
+		file = StdPrefix + name + ".frg"
+		return file, file, nil
+	}
+
+	if filepath.IsAbs(name) {
+		if file, ok := findModuleFile(name); ok {
+			return file, moduleKey(file), nil
+		}
+		return "", "", fmt.Errorf("module %q not found", name)
+	}
+	if file, ok := findModuleFile(filepath.Join(filepath.Dir(from), name)); ok {
+		return file, moduleKey(file), nil
+	}
+	if file, err := l.resolvePackage(from, name); file != "" || err != nil {
+		return file, moduleKey(file), err
+	}
+	for _, dir := range l.searchPath {
+		if file, ok := findModuleFile(filepath.Join(dir, name)); ok {
+			return file, moduleKey(file), nil
+		}
+	}
+
+	looked := []string{filepath.Dir(from)}
+	looked = append(looked, l.searchPath...)
+	return "", "", fmt.Errorf("module %q not found in %s", name, strings.Join(looked, ", "))
+}
+
+// findModuleFile tries path, and path.frg when it has no extension
+func findModuleFile(path string) (string, bool) {
+	candidates := []string{path}
+	if filepath.Ext(path) == "" {
+		candidates = append(candidates, path+".frg")
+	}
+	for _, file := range candidates {
+		if info, err := os.Stat(file); err == nil && !info.IsDir() {
+			return file, true
+		}
+	}
+	return "", false
+}
+
+// resolvePackage finds "pkg" (pkg/pkg.frg) or "pkg/file" in the packages of the project
+// of from, it returns "" and no error when name is not a package
+func (l *moduleLoader) resolvePackage(from, name string) (string, error) {
+	dir := filepath.Dir(from)
+	project, ok := l.projects[dir]
+	if !ok {
+		var err error
+		if project, err = FindProject(dir); err != nil {
+			return "", err
+		}
+		l.projects[dir] = project
+	}
+	pkg, rest, _ := strings.Cut(filepath.ToSlash(name), "/")
+	if project == nil || project.Manifest.Require(pkg) == nil {
+		return "", nil
+	}
+	if rest == "" {
+		rest = pkg
+	}
+	pkgDir, err := project.PackageDir(pkg)
+	if err != nil {
+		return "", err
+	}
+	if file, ok := findModuleFile(filepath.Join(pkgDir, filepath.FromSlash(rest))); ok {
+		return file, nil
+	}
+	return "", fmt.Errorf("package %s has no file %s", pkg, rest)
+}
+
+func moduleKey(file string) string {
+	if strings.HasPrefix(file, StdPrefix) {
+		return file
+	}
+	if abs, err := filepath.Abs(file); err == nil {
+		return abs
+	}
+	return filepath.Clean(file)
+}
+
+// isStdModule reports the names of the standard modules, without directory or extension
+func isStdModule(name string) bool {
+	if name == "" || strings.ContainsAny(name, `/\.`) {
+		return false
+	}
+	_, err := fs.Stat(std.Files, name+".frg")
+	return err == nil
+}
+
+// enter pushes file on the chain, it returns the cycle (ending with file) if file is already there
+func (l *moduleLoader) enter(ref moduleRef) []string {
+	for i, r := range l.chain {
+		if r.key != ref.key {
+			continue
+		}
+		cycle := []string{}
+		for _, r := range l.chain[i:] {
+			cycle = append(cycle, r.file)
+		}
+		return append(cycle, ref.file)
+	}
+	l.chain = append(l.chain, ref)
+	return nil
+}
+
+func (l *moduleLoader) leave() {
+	l.chain = l.chain[:len(l.chain)-1]
+}
+
+// ReadSource reads a frog file, the standard modules come from the binary
+func ReadSource(file string) ([]byte, error) {
+	if name, ok := strings.CutPrefix(file, StdPrefix); ok {
+		return std.Files.ReadFile(name)
+	}
+	return os.ReadFile(file)
+}
@@ -12,15 +12,20 @@ import (
 type ObjectType string
 
 const (
-	INTEGER_OBJ  = "INTEGER"
-	REAL_OBJ     = "REAL"
-	STRING_OBJ   = "STRING"
-	BOOLEAN_OBJ  = "BOOLEAN"
-	ARRAY_OBJ    = "ARRAY"
-	FUNCTION_OBJ = "FUNCTION"
-	NULL_OBJ     = "NULL"
-	BREAK_OBJ    = "BREAK"
-	CONTINUE_OBJ = "CONTINUE"
+	INTEGER_OBJ    = "INTEGER"
+	REAL_OBJ       = "REAL"
+	STRING_OBJ     = "STRING"
+	BOOLEAN_OBJ    = "BOOLEAN"
+	ARRAY_OBJ      = "ARRAY"
+	FUNCTION_OBJ   = "FUNCTION"
+	NULL_OBJ       = "NULL"
+	BREAK_OBJ      = "BREAK"
+	CONTINUE_OBJ   = "CONTINUE"
+	MODULE_OBJ     = "MODULE"
+	BUILTIN_OBJ    = "BUILTIN"
+	VOID_OBJ       = "VOID"
+	ENUM_OBJ       = "ENUM"
+	ENUM_VALUE_OBJ = "ENUM_VALUE"
 )
 
 // interface object that implemented by all frog types
@@ -110,17 +115,97 @@ type Function struct {
 	Name       string
 	Parameters []*Parameter
 	ReturnType Token
-	Body       *BlockStatement
-	Env        *Environment
+	// ReturnsArray is set by : FRG_Int[]
+	ReturnsArray bool
+	Body         *BlockStatement
+	Env          *Environment
+	Statics      map[string]Object // the FRG_Static variables, shared by all the calls
+	Result       string            // the variable the body assigns the return value to
 }
 
 func (f *Function) Type() ObjectType {
 	return FUNCTION_OBJ
 }
+
+// Builtin is a function written in Go, like map or sort
+type Builtin struct {
+	Name string
+	Fn   func(call *CallExpression, env *Environment, args []Object) Object
+}
+
+func (b *Builtin) Type() ObjectType {
+	return BUILTIN_OBJ
+}
+func (b *Builtin) Inspect() string {
+	return fmt.Sprintf("builtin(%s)", b.Name)
+}
+
+// parameterIndex finds the parameter a named argument is for, -1 if there is none
+func (f *Function) parameterIndex(name string) int {
+	for i, param := range f.Parameters {
+		if param.Name.Value == name {
+			return i
+		}
+	}
+	return -1
+}
+
 func (f *Function) Inspect() string {
 	return fmt.Sprintf("fn(%s)", f.Name)
 }
 
+// Enum is the name of a FRG_Enum, its members are reached with Color.Red
+type Enum struct {
+	Name    string
+	Members []*EnumValue
+}
+
+func (e *Enum) Type() ObjectType {
+	return ENUM_OBJ
+}
+func (e *Enum) Inspect() string {
+	return fmt.Sprintf("enum(%s)", e.Name)
+}
+
+// member finds Color.Red, nil if the enum has no such member
+func (e *Enum) member(name string) *EnumValue {
+	for _, m := range e.Members {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// EnumValue is a member of a FRG_Enum, a number that knows its enum
+type EnumValue struct {
+	Enum  *Enum
+	Name  string
+	Value int64
+}
+
+func (v *EnumValue) Type() ObjectType {
+	return ENUM_VALUE_OBJ
+}
+func (v *EnumValue) Inspect() string {
+	return v.Enum.Name + "." + v.Name
+}
+
+// Module is a file loaded by FRG_Use, its globals live in its own environment
+type Module struct {
+	Name    string
+	Path    string
+	Env     *Environment
+	Exports map[string]bool // names declared with FRG_Export
+}
+
+func (m *Module) Type() ObjectType {
+	return MODULE_OBJ
+}
+func (m *Module) Inspect() string {
+	return fmt.Sprintf("module(%s)", m.Name)
+}
+
 type Null struct{}
 
 func (n *Null) Type() ObjectType {
@@ -130,6 +215,16 @@ func (n *Null) Inspect() string {
 	return "null"
 }
 
+// Void is what a FRG_Void function gives back, only a call statement may drop it
+type Void struct{}
+
+func (v *Void) Type() ObjectType {
+	return VOID_OBJ
+}
+func (v *Void) Inspect() string {
+	return "void"
+}
+
 type Break struct{}
 
 func (b *Break) Type() ObjectType {
@@ -155,4 +250,5 @@ var (
 	FALSE    = &Boolean{Value: false}
 	BREAK    = &Break{}
 	CONTINUE = &Continue{}
+	VOID     = &Void{}
 )
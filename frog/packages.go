@@ -0,0 +1,522 @@
+// Copyright (C) by abdenour souane
+// you have a right to modify it upgrade it or do whatever you want
+// but u have to keep my name on it
+
+package frog
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// a project shares frog libraries ("packages") with a frog.mod file next to its sources:
+//
+//	## frog.mod
+//	package myapp
+//	require mathx ../libs/mathx
+//	require strutil https://example.com/strutil.git v1.2.0
+//
+// a source is a git repository when it is a URL or ends with .git (the version is a tag,
+// a branch or a commit, HEAD without it), a local directory otherwise
+// `frog get` copies every package in the cache and pins it in frog.lock (the git commit
+// or the sha256 of the files), `frog vendor` copies the pinned packages in vendor/
+// then FRG_Use "mathx"# reads mathx/mathx.frg and FRG_Use "mathx/geo"# mathx/geo.frg,
+// from vendor/ when it exists, from the cache otherwise
+
+const (
+	ManifestFile = "frog.mod"
+	LockFile     = "frog.lock"
+	VendorDir    = "vendor"
+
+	// CacheEnv overrides the directory of the downloaded packages
+	CacheEnv = "FRG_CACHE"
+)
+
+type Requirement struct {
+	Name    string
+	Source  string
+	Version string // git only, "" for HEAD
+}
+
+type Manifest struct {
+	Package  string
+	Requires []Requirement
+}
+
+// LockedPackage is a requirement as it was fetched
+type LockedPackage struct {
+	Requirement
+	Sum string // git commit, or "sha256-..." of the files of a directory
+}
+
+// Lock is the content of frog.lock, sorted by name
+type Lock struct {
+	Packages []LockedPackage
+}
+
+// Project is a directory with a frog.mod
+type Project struct {
+	Dir      string
+	Manifest *Manifest
+	Lock     *Lock
+}
+
+// IsGitSource reports the sources fetched with git
+func IsGitSource(source string) bool {
+	return strings.Contains(source, "://") || strings.HasPrefix(source, "git@") || strings.HasSuffix(source, ".git")
+}
+
+// ReadManifest parses a frog.mod
+func ReadManifest(path string) (*Manifest, error) {
+	m := &Manifest{}
+	err := readFields(path, func(line int, fields []string) error {
+		switch {
+		case fields[0] == "package" && len(fields) == 2:
+			m.Package = fields[1]
+		case fields[0] == "require" && (len(fields) == 3 || len(fields) == 4):
+			r := Requirement{Name: fields[1], Source: fields[2]}
+			if len(fields) == 4 {
+				r.Version = fields[3]
+			}
+			if err := r.Check(); err != nil {
+				return fmt.Errorf("%s:%d: %v", path, line, err)
+			}
+			if m.Require(r.Name) != nil {
+				return fmt.Errorf("%s:%d: package %s is required twice", path, line, r.Name)
+			}
+			m.Requires = append(m.Requires, r)
+		default:
+			return fmt.Errorf("%s:%d: expected `package <name>` or `require <name> <source> [version]`", path, line)
+		}
+		return nil
+	})
+	return m, err
+}
+
+// Check reports a bad package name, or a version without git
+func (r Requirement) Check() error {
+	if moduleName(r.Name) != r.Name {
+		return fmt.Errorf("%q is not a valid package name", r.Name)
+	}
+	if isStdModule(r.Name) {
+		return fmt.Errorf("package %s hides the standard module %s", r.Name, r.Name)
+	}
+	if strings.HasPrefix(r.Source, "-") || strings.HasPrefix(r.Version, "-") {
+		// git would take it for an option
+		return fmt.Errorf("package %s: a source or a version cannot start with -", r.Name)
+	}
+	if r.Version != "" && !IsGitSource(r.Source) {
+		return fmt.Errorf("package %s: a version needs a git source", r.Name)
+	}
+	return nil
+}
+
+// Require returns the requirement of the package name, nil if there is none
+func (m *Manifest) Require(name string) *Requirement {
+	for i := range m.Requires {
+		if m.Requires[i].Name == name {
+			return &m.Requires[i]
+		}
+	}
+	return nil
+}
+
+func (m *Manifest) Write(path string) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "## frog packages, `frog get` updates frog.lock\n")
+	if m.Package != "" {
+		fmt.Fprintf(&b, "package %s\n", m.Package)
+	}
+	if len(m.Requires) > 0 {
+		b.WriteString("\n")
+	}
+	for _, r := range m.Requires {
+		fmt.Fprintf(&b, "require %s %s", r.Name, r.Source)
+		if r.Version != "" {
+			fmt.Fprintf(&b, " %s", r.Version)
+		}
+		b.WriteString("\n")
+	}
+	return os.WriteFile(path, b.Bytes(), 0o644)
+}
+
+// ReadLock parses a frog.lock, a missing file is an empty lock
+func ReadLock(path string) (*Lock, error) {
+	lock := &Lock{}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return lock, nil
+	}
+	err := readFields(path, func(line int, fields []string) error {
+		if len(fields) != 4 {
+			return fmt.Errorf("%s:%d: expected `<name> <source> <version> <sum>`", path, line)
+		}
+		p := LockedPackage{Requirement: Requirement{Name: fields[0], Source: fields[1], Version: fields[2]}, Sum: fields[3]}
+		if p.Version == "-" {
+			p.Version = ""
+		}
+		if err := p.Check(); err != nil {
+			return fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		lock.Packages = append(lock.Packages, p)
+		return nil
+	})
+	return lock, err
+}
+
+// Check reports a bad requirement, or a sum that is neither a git commit nor a sha256,
+// the sum names the directory of the package in the cache
+func (p LockedPackage) Check() error {
+	if err := p.Requirement.Check(); err != nil {
+		return err
+	}
+	sum, files := strings.CutPrefix(p.Sum, "sha256-")
+	_, err := hex.DecodeString(sum)
+	switch {
+	case err != nil:
+	case files && len(sum) == sha256.Size*2:
+		return nil
+	case !files && (len(sum) == 40 || len(sum) == 64): // sha-1 or sha-256 git
+		return nil
+	}
+	return fmt.Errorf("package %s: %q is not a git commit or a sha256 sum", p.Name, p.Sum)
+}
+
+// Find returns the pinned package name, nil if it is not locked
+func (l *Lock) Find(name string) *LockedPackage {
+	for i := range l.Packages {
+		if l.Packages[i].Name == name {
+			return &l.Packages[i]
+		}
+	}
+	return nil
+}
+
+func (l *Lock) Write(path string) error {
+	sort.Slice(l.Packages, func(i, j int) bool { return l.Packages[i].Name < l.Packages[j].Name })
+	var b bytes.Buffer
+	b.WriteString("## written by frog get, do not edit\n")
+	for _, p := range l.Packages {
+		version := p.Version
+		if version == "" {
+			version = "-"
+		}
+		fmt.Fprintf(&b, "%s %s %s %s\n", p.Name, p.Source, version, p.Sum)
+	}
+	return os.WriteFile(path, b.Bytes(), 0o644)
+}
+
+// readFields calls fn with the words of every line, without the ## comments
+func readFields(path string, fn func(line int, fields []string) error) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if i := strings.Index(text, "##"); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if err := fn(line, fields); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// FindProject looks for a frog.mod in dir and its parents, nil when there is none
+func FindProject(dir string) (*Project, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ManifestFile)); err == nil {
+			return OpenProject(dir)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// OpenProject reads the frog.mod and frog.lock of dir
+func OpenProject(dir string) (*Project, error) {
+	manifest, err := ReadManifest(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	lock, err := ReadLock(filepath.Join(dir, LockFile))
+	if err != nil {
+		return nil, err
+	}
+	return &Project{Dir: dir, Manifest: manifest, Lock: lock}, nil
+}
+
+// CacheDir is where the packages are downloaded, $FRG_CACHE or the user cache
+func CacheDir() (string, error) {
+	if dir := os.Getenv(CacheEnv); dir != "" {
+		return filepath.Abs(dir)
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "frog", "pkg"), nil
+}
+
+// PackageDir returns the directory of the files of a required package
+func (p *Project) PackageDir(name string) (string, error) {
+	if p.Manifest.Require(name) == nil {
+		return "", fmt.Errorf("package %s is not required by %s", name, filepath.Join(p.Dir, ManifestFile))
+	}
+	vendored := filepath.Join(p.Dir, VendorDir, name)
+	if info, err := os.Stat(vendored); err == nil && info.IsDir() {
+		return vendored, nil
+	}
+	locked := p.Lock.Find(name)
+	if locked == nil {
+		return "", fmt.Errorf("package %s is not in %s, run frog get", name, LockFile)
+	}
+	cache, err := CacheDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(cache, name+"@"+locked.Sum)
+	if _, err := os.Stat(dir); err != nil {
+		return "", fmt.Errorf("package %s is not downloaded, run frog get", name)
+	}
+	return dir, nil
+}
+
+// Get fetches the required packages in the cache and writes frog.lock, the ones already
+// locked keep their commit unless update is set, a changed source or version is fetched again
+// it prints what it fetches on log
+func (p *Project) Get(update bool, log io.Writer) error {
+	cache, err := CacheDir()
+	if err != nil {
+		return err
+	}
+	lock := &Lock{}
+	for _, r := range p.Manifest.Requires {
+		pin := ""
+		if old := p.Lock.Find(r.Name); old != nil && !update && old.Source == r.Source && old.Version == r.Version {
+			pin = old.Sum
+			if _, err := os.Stat(filepath.Join(cache, r.Name+"@"+pin)); err == nil {
+				lock.Packages = append(lock.Packages, *old)
+				continue
+			}
+		}
+		locked, err := p.fetch(r, pin, cache)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(log, "get %s %s\n", r.Name, locked.Sum)
+		lock.Packages = append(lock.Packages, locked)
+	}
+	p.Lock = lock
+	return lock.Write(filepath.Join(p.Dir, LockFile))
+}
+
+// fetch copies a package in the cache, at pin ("" for the version of the requirement)
+func (p *Project) fetch(r Requirement, pin, cache string) (LockedPackage, error) {
+	locked := LockedPackage{Requirement: r}
+	if !IsGitSource(r.Source) {
+		src := r.Source
+		if !filepath.IsAbs(src) {
+			src = filepath.Join(p.Dir, src)
+		}
+		sum, err := hashDir(src)
+		if err != nil {
+			return locked, fmt.Errorf("package %s: %v", r.Name, err)
+		}
+		if pin != "" && pin != sum {
+			return locked, fmt.Errorf("package %s: the files of %s changed since frog.lock, run frog get -u", r.Name, r.Source)
+		}
+		locked.Sum = sum
+		return locked, copyPackage(src, filepath.Join(cache, r.Name+"@"+sum))
+	}
+
+	tmp, err := os.MkdirTemp("", "frog-get-")
+	if err != nil {
+		return locked, err
+	}
+	defer os.RemoveAll(tmp)
+	source := r.Source
+	if !strings.Contains(source, "://") && !strings.HasPrefix(source, "git@") && !filepath.IsAbs(source) {
+		// a local repository, relative to frog.mod
+		source = filepath.Join(p.Dir, source)
+	}
+	ref := pin
+	if ref == "" {
+		ref = r.Version
+	}
+	if ref == "" {
+		ref = "HEAD"
+	}
+	// git would take them for options, frog.lock is not checked like frog.mod
+	if strings.HasPrefix(source, "-") || strings.HasPrefix(ref, "-") {
+		return locked, fmt.Errorf("package %s: a source or a version cannot start with -", r.Name)
+	}
+	if _, err := git("", "clone", "--quiet", "--no-checkout", "--", source, tmp); err != nil {
+		return locked, fmt.Errorf("package %s: %v", r.Name, err)
+	}
+	// the ref goes before --, what follows it would be files
+	if _, err := git(tmp, "checkout", "--quiet", ref, "--"); err != nil {
+		return locked, fmt.Errorf("package %s: version %s: %v", r.Name, ref, err)
+	}
+	commit, err := git(tmp, "rev-parse", "HEAD")
+	if err != nil {
+		return locked, fmt.Errorf("package %s: %v", r.Name, err)
+	}
+	locked.Sum = commit
+	return locked, copyPackage(tmp, filepath.Join(cache, r.Name+"@"+commit))
+}
+
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Vendor copies the locked packages in vendor/ so the project builds without the cache,
+// the old vendor/ is kept until every package is copied
+func (p *Project) Vendor(log io.Writer) error {
+	cache, err := CacheDir()
+	if err != nil {
+		return err
+	}
+	sources := make([]string, len(p.Manifest.Requires))
+	for i, r := range p.Manifest.Requires {
+		locked := p.Lock.Find(r.Name)
+		if locked == nil || locked.Source != r.Source || locked.Version != r.Version {
+			return fmt.Errorf("package %s is not in %s, run frog get", r.Name, LockFile)
+		}
+		sources[i] = filepath.Join(cache, r.Name+"@"+locked.Sum)
+		if _, err := os.Stat(sources[i]); err != nil {
+			return fmt.Errorf("package %s is not downloaded, run frog get", r.Name)
+		}
+	}
+
+	tmp, err := os.MkdirTemp(p.Dir, VendorDir+"-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	for i, r := range p.Manifest.Requires {
+		if err := copyPackage(sources[i], filepath.Join(tmp, r.Name)); err != nil {
+			return err
+		}
+	}
+	vendor := filepath.Join(p.Dir, VendorDir)
+	if err := os.RemoveAll(vendor); err != nil {
+		return err
+	}
+	// MkdirTemp made it private
+	if err := os.Chmod(tmp, 0o755); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, vendor); err != nil {
+		return err
+	}
+	for _, r := range p.Manifest.Requires {
+		fmt.Fprintf(log, "vendor %s %s\n", r.Name, p.Lock.Find(r.Name).Sum)
+	}
+	return nil
+}
+
+// packageFiles lists the .frg files of a package, relative to dir and sorted
+func packageFiles(dir string) ([]string, error) {
+	files := []string{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && filepath.Ext(path) == ".frg" {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// hashDir is the sum of the names and contents of the .frg files of dir
+func hashDir(dir string) (string, error) {
+	files, err := packageFiles(dir)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	for _, file := range files {
+		content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(file)))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s %d\n", file, len(content))
+		h.Write(content)
+	}
+	return "sha256-" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// copyPackage copies the .frg files of src in dst, a dst already there is kept
+func copyPackage(src, dst string) error {
+	if _, err := os.Stat(dst); err == nil {
+		return nil
+	}
+	files, err := packageFiles(src)
+	if err != nil {
+		return err
+	}
+	tmp := dst + ".tmp"
+	os.RemoveAll(tmp)
+	for _, file := range files {
+		content, err := os.ReadFile(filepath.Join(src, filepath.FromSlash(file)))
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(tmp, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, content, 0o644); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(tmp, 0o755); err != nil {
+		return err
+	}
+	// renamed at the end, a dst is always complete
+	return os.Rename(tmp, dst)
+}
@@ -0,0 +1,223 @@
+// Copyright (C) by abdenour souane
+// you have a right to modify it upgrade it or do whatever you want
+// but u have to keep my name on it
+
+package frog
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// the packages come from a local directory and a local bare git repository,
+// nothing is downloaded
+
+func TestPackages(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	root := t.TempDir()
+	t.Setenv(CacheEnv, filepath.Join(root, "cache"))
+
+	writeFile(t, filepath.Join(root, "libs", "mathx", "mathx.frg"), `FRG_Begin
+    FRG_Export FRG_Fn double(FRG_Int x) : FRG_Int
+    Begin
+        double := x * 2#
+    End
+FRG_End
+`)
+
+	// strutil: v1.0.0 says "one", the next commit "two"
+	work := filepath.Join(root, "work")
+	writeFile(t, filepath.Join(work, "strutil.frg"), versionModule("one"))
+	runGit(t, work, "init", "--quiet")
+	runGit(t, work, "add", ".")
+	runGit(t, work, "commit", "--quiet", "-m", "one")
+	runGit(t, work, "tag", "v1.0.0")
+	tagged := runGit(t, work, "rev-parse", "HEAD")
+	writeFile(t, filepath.Join(work, "strutil.frg"), versionModule("two"))
+	runGit(t, work, "commit", "--quiet", "-am", "two")
+	runGit(t, root, "clone", "--quiet", "--bare", work, "strutil.git")
+
+	app := filepath.Join(root, "app")
+	writeFile(t, filepath.Join(app, ManifestFile), `package app
+require mathx ../libs/mathx
+require strutil ../strutil.git v1.0.0
+`)
+	writeFile(t, filepath.Join(app, "main.frg"), `FRG_Begin
+    FRG_Use "mathx"#
+    FRG_Use VERSION From "strutil"#
+    FRG_Print mathx.double(21), " ", VERSION#
+FRG_End
+`)
+
+	project := openTestProject(t, app)
+	if err := project.Get(false, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if got := project.Lock.Find("strutil").Sum; got != tagged {
+		t.Errorf("strutil locked at %s, want the commit of v1.0.0 %s", got, tagged)
+	}
+	if got := project.Lock.Find("mathx").Sum; !strings.HasPrefix(got, "sha256-") {
+		t.Errorf("mathx locked at %q, want a sha256", got)
+	}
+	checkOutput(t, app, "42 one")
+
+	// a changed requirement is fetched again
+	writeFile(t, filepath.Join(app, ManifestFile), `package app
+require mathx ../libs/mathx
+require strutil ../strutil.git
+`)
+	project = openTestProject(t, app)
+	if err := project.Get(false, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	checkOutput(t, app, "42 two")
+
+	// then the lock wins over the new commits until -u
+	writeFile(t, filepath.Join(work, "strutil.frg"), versionModule("three"))
+	runGit(t, work, "commit", "--quiet", "-am", "three")
+	runGit(t, work, "push", "--quiet", filepath.Join(root, "strutil.git"), "HEAD")
+	project = openTestProject(t, app)
+	if err := project.Get(false, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	checkOutput(t, app, "42 two")
+	if err := project.Get(true, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	checkOutput(t, app, "42 three")
+
+	// vendor/ is used without the cache
+	project = openTestProject(t, app)
+	if err := project.Vendor(io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(CacheEnv, filepath.Join(root, "empty"))
+	checkOutput(t, app, "42 three")
+
+	// a failing frog vendor leaves the old vendor/ alone
+	if err := project.Vendor(io.Discard); err == nil || !strings.Contains(err.Error(), "run frog get") {
+		t.Fatalf("got %v, want an error asking to run frog get", err)
+	}
+	checkOutput(t, app, "42 three")
+}
+
+// a source or a version starting with - would be an option of git
+func TestPackageOption(t *testing.T) {
+	root := t.TempDir()
+	for _, require := range []string{"require x --upload-pack=touch.git", "require x repo.git --output=y"} {
+		writeFile(t, filepath.Join(root, ManifestFile), require+"\n")
+		if _, err := ReadManifest(filepath.Join(root, ManifestFile)); err == nil || !strings.Contains(err.Error(), "cannot start with -") {
+			t.Errorf("%s: got %v, want an error", require, err)
+		}
+	}
+	project := &Project{Dir: root}
+	_, err := project.fetch(Requirement{Name: "x", Source: filepath.Join(root, "x.git")}, "--orphan=y", root)
+	if err == nil || !strings.Contains(err.Error(), "cannot start with -") {
+		t.Errorf("a locked version starting with -: got %v, want an error", err)
+	}
+}
+
+// the sum of frog.lock is a directory name of the cache, a crafted one must not leave it
+func TestPackageLockSum(t *testing.T) {
+	root := t.TempDir()
+	lock := filepath.Join(root, LockFile)
+	commit := strings.Repeat("a1", 20)
+	sum := "sha256-" + strings.Repeat("0f", 32)
+	for _, line := range []string{"mathx ../mathx - " + sum, "x x.git v1 " + commit, "x x.git - " + commit + commit[:24]} {
+		writeFile(t, lock, line+"\n")
+		if _, err := ReadLock(lock); err != nil {
+			t.Errorf("%s: %v", line, err)
+		}
+	}
+	for _, line := range []string{
+		"mathx ../mathx - x/../../..",
+		"mathx ../mathx - sha256-../../etc",
+		"mathx ../mathx - sha256-" + commit,
+		"x x.git - " + commit[:39],
+		"x x.git - " + commit[:38] + "/.",
+		"../x x.git - " + commit,
+	} {
+		writeFile(t, lock, line+"\n")
+		if _, err := ReadLock(lock); err == nil || !strings.Contains(err.Error(), LockFile+":1:") {
+			t.Errorf("%s: got %v, want an error", line, err)
+		}
+	}
+}
+
+func TestPackageNotDownloaded(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv(CacheEnv, filepath.Join(root, "cache"))
+	writeFile(t, filepath.Join(root, ManifestFile), "require mathx ../mathx\n")
+	file := filepath.Join(root, "main.frg")
+	parser := NewParser(NewFileLexer(file, "FRG_Begin\nFRG_Use \"mathx\"#\nFRG_End\n"))
+	parser.ParseProgram()
+	diagnostics := parser.Diagnostics()
+	if len(diagnostics) != 1 || !strings.Contains(diagnostics[0].Message, "run frog get") {
+		t.Fatalf("got %v, want a single error asking to run frog get", diagnostics)
+	}
+}
+
+func versionModule(version string) string {
+	return "FRG_Begin\n    FRG_Export FRG_Strg VERSION#\n    VERSION := \"" + version + "\"#\nFRG_End\n"
+}
+
+func openTestProject(t *testing.T, dir string) *Project {
+	t.Helper()
+	project, err := OpenProject(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return project
+}
+
+// checkOutput runs main.frg of dir
+func checkOutput(t *testing.T, dir, want string) {
+	t.Helper()
+	file := filepath.Join(dir, "main.frg")
+	code, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser := NewParser(NewFileLexer(file, string(code)))
+	program := parser.ParseProgram()
+	if parser.IsThereAnyErrors() {
+		t.Fatalf("parse errors: %v", parser.Diagnostics())
+	}
+	var out bytes.Buffer
+	env := NewEnvironment()
+	env.Runtime().Out = &out
+	if result := Eval(program, env); isError(result) {
+		t.Fatal(result.Inspect())
+	}
+	if out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=frog", "-c", "user.email=frog@example.com"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -8,9 +8,9 @@ package frog
 
 import (
 	"bytes"
-	"fmt"
-	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 )
 
 type Node interface {
@@ -54,6 +54,17 @@ type DeclarationStatement struct {
 	Token       Token // The type token (FRG_Int, FRG_Real, FRG_Strg)
 	IsArray     bool  // FRG_Int[]
 	Identifiers []*Identifier
+	// Values has one entry for each identifier, nil when the variable
+	// starts at the zero value of its type: FRG_Int a := 10, b#
+	Values []Expression
+}
+
+// value returns the initializer of the i-th identifier, nil if there is none
+func (ds *DeclarationStatement) value(i int) Expression {
+	if i < len(ds.Values) {
+		return ds.Values[i]
+	}
+	return nil
 }
 
 func (ds *DeclarationStatement) statementNode() {
@@ -72,6 +83,9 @@ func (ds *DeclarationStatement) String() string {
 	out.WriteString(" ")
 	for i, ident := range ds.Identifiers {
 		out.WriteString(ident.String())
+		if value := ds.value(i); value != nil {
+			out.WriteString(" := " + value.String())
+		}
 		if i < len(ds.Identifiers)-1 {
 			out.WriteString(", ")
 		}
@@ -82,16 +96,48 @@ func (ds *DeclarationStatement) String() string {
 
 // helper structure
 type Parameter struct {
-	Type Token // FRG_Int, FRG_Real, FRG_Strg
-	Name *Identifier
+	Type    Token // FRG_Int, FRG_Real, FRG_Strg, FRG_Fn
+	IsArray bool  // FRG_Int[], the caller's array is shared, not copied
+	Name    *Identifier
+	Default Expression // FRG_Int size := 10, nil when the argument is required
+	// Variadic is set by FRG_Int... nums, the last parameter gets the extra
+	// arguments in an array
+	Variadic bool
+}
+
+func (p *Parameter) String() string {
+	out := p.TypeString() + " " + p.Name.String()
+	if p.Default != nil {
+		out += " := " + p.Default.String()
+	}
+	return out
+}
+
+// TypeString gives the type as written, FRG_Int, FRG_Int[] or FRG_Int...
+func (p *Parameter) TypeString() string {
+	if p.Variadic {
+		return typeString(p.Type, p.IsArray) + "..."
+	}
+	return typeString(p.Type, p.IsArray)
+}
+
+func typeString(typ Token, isArray bool) string {
+	if isArray {
+		return typ.Literal + "[]"
+	}
+	return typ.Literal
 }
 
 type FunctionDeclarationStatement struct {
 	Token      Token
 	Name       *Identifier
-	ReturnType Token // FRG_Int, FRG_Real, FRG_Strg
-	Parameters []*Parameter
-	Body       *BlockStatement
+	ReturnType Token // FRG_Int, FRG_Real, FRG_Strg, FRG_Fn, FRG_Void
+	// ReturnsArray is set by : FRG_Int[]
+	ReturnsArray bool
+	Parameters   []*Parameter
+	Body         *BlockStatement
+	// brokenParameters is set when the parameter list had an error, it misses some
+	brokenParameters bool
 }
 
 func (fds *FunctionDeclarationStatement) statementNode() {
@@ -108,20 +154,71 @@ func (fds *FunctionDeclarationStatement) String() string {
 	out.WriteString(fds.Name.String())
 	out.WriteString("(")
 	for i, param := range fds.Parameters {
-		out.WriteString(param.Type.Literal)
-		out.WriteString(" ")
-		out.WriteString(param.Name.String())
+		out.WriteString(param.String())
 		if i < len(fds.Parameters)-1 {
 			out.WriteString(", ")
 		}
 	}
 	out.WriteString(") : ")
-	out.WriteString(fds.ReturnType.Literal)
+	out.WriteString(typeString(fds.ReturnType, fds.ReturnsArray))
 	out.WriteString("\n")
 	out.WriteString(fds.Body.String())
 	return out.String()
 }
 
+// Void reports a function without a return value, : FRG_Void or no type at all
+func (fds *FunctionDeclarationStatement) Void() bool {
+	return fds.ReturnType.Type == TokenFRGVoid
+}
+
+// Anonymous reports the declaration inside a FunctionLiteral, its name is the FRG_Fn keyword
+func (fds *FunctionDeclarationStatement) Anonymous() bool {
+	return fds.Name != nil && fds.Name.Token.Type == TokenFRGFn
+}
+
+// staticConflict tells why a FRG_Static of a function cannot be called name, "" if it can:
+// the parameters and the return value are set at every call, the static would lose its value
+func staticConflict(name, result string, params []*Parameter) string {
+	if name == result {
+		return "the return value"
+	}
+	for _, param := range params {
+		if param.Name.Value == name {
+			return "a parameter"
+		}
+	}
+	return ""
+}
+
+// AnonymousResult is the variable an anonymous function assigns its value to
+const AnonymousResult = "Result"
+
+// FunctionLiteral is an anonymous function, a value like 12 or "abc":
+// FRG_Fn(FRG_Int x) : FRG_Int := x * 2
+type FunctionLiteral struct {
+	Token    Token                         // FRG_Fn
+	Function *FunctionDeclarationStatement // named AnonymousResult
+}
+
+func (fl *FunctionLiteral) expressionNode()      {}
+func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) String() string {
+	var out bytes.Buffer
+	out.WriteString(fl.TokenLiteral())
+	out.WriteString("(")
+	for i, param := range fl.Function.Parameters {
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		out.WriteString(param.String())
+	}
+	out.WriteString(") : ")
+	out.WriteString(typeString(fl.Function.ReturnType, fl.Function.ReturnsArray))
+	out.WriteString(" ")
+	out.WriteString(fl.Function.Body.String())
+	return out.String()
+}
+
 type AssignmentStatement struct {
 	Token Token
 	Left  Expression // ID name as expression
@@ -214,6 +311,31 @@ func (is *IfStatement) String() string {
 	return out.String()
 }
 
+// Assert [x == 2] "x should be 2"#
+// stops the program when the condition is false, the message is optional
+type AssertStatement struct {
+	Token     Token      // Assert
+	Condition Expression // x == 2
+	Message   Expression // "x should be 2" or nil
+}
+
+func (as *AssertStatement) statementNode() {
+	// read at line 72 :)
+}
+func (as *AssertStatement) TokenLiteral() string { return as.Token.Literal }
+func (as *AssertStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("Assert [")
+	out.WriteString(as.Condition.String())
+	out.WriteString("]")
+	if as.Message != nil {
+		out.WriteString(" ")
+		out.WriteString(as.Message.String())
+	}
+	out.WriteString(" #")
+	return out.String()
+}
+
 type RepeatStatement struct {
 	Token     Token       // Repeat
 	Condition Expression  // condition expression
@@ -275,9 +397,18 @@ func (cs *ContinueStatement) statementNode() {
 func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
 func (cs *ContinueStatement) String() string       { return cs.TokenLiteral() + " #" }
 
+// every file used is a module with its own globals, only its FRG_Export names can be used:
+//
+//	FRG_Use "std/std.frg"#              std.pow(2, 10)
+//	FRG_Use "std/std.frg" As math#      math.pow(2, 10)
+//	FRG_Use pow, PI From "std/std.frg"# pow(2, 10)
 type UseStatement struct {
 	Token    Token          // The FRG_Use token
 	Filename *StringLiteral // frog file name (frog code) | string litteral
+	Alias    *Identifier    // name of the module, from the file name without As (nil with From)
+	Names    []*Identifier  // the names imported with From
+	Path     string         // the file that was read
+	Module   *Program       // its AST, the same for every FRG_Use of the file
 }
 
 func (us *UseStatement) statementNode() {
@@ -288,11 +419,120 @@ func (us *UseStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(us.TokenLiteral())
 	out.WriteString(" ")
-	out.WriteString(us.Filename.String())
+	if len(us.Names) > 0 {
+		for i, name := range us.Names {
+			if i > 0 {
+				out.WriteString(", ")
+			}
+			out.WriteString(name.Value)
+		}
+		out.WriteString(" From ")
+	}
+	out.WriteString("\"" + us.Filename.Value + "\"")
+	if us.Alias != nil && us.Alias.Token.Type == TokenIdentifier {
+		out.WriteString(" As " + us.Alias.Value)
+	}
+	out.WriteString(" #")
+	return out.String()
+}
+
+// FRG_Export FRG_Fn pow(...)  or  FRG_Export FRG_Real PI#
+// the names declared can be used by the files using this one
+type ExportStatement struct {
+	Token     Token     // FRG_Export
+	Statement Statement // a declaration or a FRG_Fn
+}
+
+func (es *ExportStatement) statementNode() {
+	// read at line 72 :)
+}
+func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExportStatement) String() string {
+	return es.TokenLiteral() + " " + es.Statement.String()
+}
+
+// Names returns the names the statement exports
+func (es *ExportStatement) Names() []*Identifier {
+	switch s := es.Statement.(type) {
+	case *DeclarationStatement:
+		return s.Identifiers
+	case *ConstStatement:
+		return s.Names
+	case *EnumStatement:
+		return []*Identifier{s.Name}
+	case *FunctionDeclarationStatement:
+		if s.Name != nil {
+			return []*Identifier{s.Name}
+		}
+	}
+	return nil
+}
+
+// FRG_Static FRG_Int counter#  inside a FRG_Fn
+// the variables keep their value from one call of the function to the next,
+// they start at their initializer, run at the first call, or at the zero
+// value of their type (0, 0.0, "" or an empty array)
+type StaticStatement struct {
+	Token       Token // FRG_Static
+	Declaration *DeclarationStatement
+}
+
+func (ss *StaticStatement) statementNode() {
+	// read at line 72 :)
+}
+func (ss *StaticStatement) TokenLiteral() string { return ss.Token.Literal }
+func (ss *StaticStatement) String() string {
+	return ss.TokenLiteral() + " " + ss.Declaration.String()
+}
+
+// FRG_Const FRG_Real PI := 3.14, TAU := PI * 2#
+// the names get their value once, assigning them or reading them with
+// FRG_Input is an error. for an array only the name is constant, not the elements
+type ConstStatement struct {
+	Token   Token // FRG_Const
+	Type    Token // FRG_Int, FRG_Real, FRG_Strg, FRG_Fn
+	IsArray bool
+	Names   []*Identifier
+	Values  []Expression // one for each name
+}
+
+func (cs *ConstStatement) statementNode() {
+	// read at line 72 :)
+}
+func (cs *ConstStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ConstStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString(cs.TokenLiteral() + " " + typeString(cs.Type, cs.IsArray) + " ")
+	for i, name := range cs.Names {
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		out.WriteString(name.String() + " := " + cs.Values[i].String())
+	}
 	out.WriteString(" #")
 	return out.String()
 }
 
+// FRG_Enum Color Begin Red, Green, Blue End
+// the members are numbered from 0 and always used with the name of the enum: Color.Red
+type EnumStatement struct {
+	Token   Token // FRG_Enum
+	Name    *Identifier
+	Members []*Identifier
+}
+
+func (es *EnumStatement) statementNode() {
+	// same as the others
+}
+func (es *EnumStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *EnumStatement) String() string {
+	members := []string{}
+	for _, member := range es.Members {
+		members = append(members, member.Value)
+	}
+	return es.TokenLiteral() + " " + es.Name.Value + " Begin " + strings.Join(members, ", ") + " End"
+}
+
 // ExpressionStatement is a statement that consists of a single expression.
 type ExpressionStatement struct {
 	Token      Token // the first token of the expression
@@ -464,6 +704,21 @@ func (asl *ArraySizeLiteral) String() string {
 	return out.String()
 }
 
+// std.pow : a name of a module
+type MemberExpression struct {
+	Token  Token // The . token
+	Object Expression
+	Member *Identifier
+}
+
+func (me *MemberExpression) expressionNode() {
+	// mark as expression node
+}
+func (me *MemberExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MemberExpression) String() string {
+	return me.Object.String() + "." + me.Member.String()
+}
+
 type IndexExpression struct {
 	Token Token // The [ token
 	Left  Expression
@@ -510,6 +765,32 @@ func (ce *CallExpression) String() string {
 	return out.String()
 }
 
+// NamedArgument is an argument given by the name of its parameter:
+// draw(x := 1, color := "red")
+type NamedArgument struct {
+	Token Token // The := token
+	Name  *Identifier
+	Value Expression
+}
+
+func (na *NamedArgument) expressionNode()      {}
+func (na *NamedArgument) TokenLiteral() string { return na.Token.Literal }
+func (na *NamedArgument) String() string {
+	return na.Name.String() + " := " + na.Value.String()
+}
+
+// SpreadArgument gives the elements of an array as arguments: sum(numbers...)
+type SpreadArgument struct {
+	Token Token // The ... token
+	Value Expression
+}
+
+func (sa *SpreadArgument) expressionNode()      {}
+func (sa *SpreadArgument) TokenLiteral() string { return sa.Token.Literal }
+func (sa *SpreadArgument) String() string {
+	return sa.Value.String() + "..."
+}
+
 // =============================================================================
 // parser : after implements all structures for each statement and expression interfaces
 // =============================================================================
@@ -599,6 +880,7 @@ const (
 	INDEX       // []
 	CALL        // function()
 	PREFIX      // -X
+	MEMBER      // module.name
 )
 
 // map of TokenType and OpLevels
@@ -616,6 +898,7 @@ var precedences = map[TokenType]int{
 	TokenModulo:       PRODUCT,
 	TokenLBracket:     INDEX,
 	TokenLParen:       CALL,
+	TokenDot:          MEMBER,
 }
 
 type (
@@ -631,14 +914,39 @@ type Parser struct {
 	// used to walk througth the tokens
 	lexer *Lexer
 
-	// array of strings for error parsing happend
-	errors []string
+	// diagnostics for every parsing error happend
+	errors []Diagnostic
+
+	// set after an error until the broken statement is skipped (see synchronize)
+	// so one mistake gives one error and not one for every token after it
+	panicking bool
 
 	// currentToken and peekToken two fields of Token struct type
 	// have informations of the current token
 	currentToken Token
 	peekToken    Token
 
+	// the token before currentToken and the tokens pushed back by backUp
+	previousToken Token
+	pending       []Token
+
+	// first token of the statement being parsed
+	statementStart Token
+
+	// Begin and Repeat waiting for their End/Until
+	openBlocks  int
+	openRepeats int
+
+	// FRG_Fn bodies being parsed, innermost last, FRG_Static is only allowed inside
+	openFunctions []*FunctionDeclarationStatement
+
+	// FRG_Use is an error, for the sandbox
+	noIncludes bool
+
+	// finds and caches the files of FRG_Use,
+	// shared with the parsers of those files so each one is read once
+	loader *moduleLoader
+
 	// two maps with same key (TokenType) and diffrent values prefixParseFn and infixParseFn
 	// which is function type that defined at line 574
 	// for each token will have it's own function parser , that parse the token
@@ -653,7 +961,7 @@ type Parser struct {
 func NewParser(l *Lexer) *Parser {
 	p := &Parser{
 		lexer:  l,
-		errors: []string{},
+		errors: []Diagnostic{},
 	}
 
 	p.prefixParseFns = make(map[TokenType]prefixParseFn)
@@ -666,6 +974,7 @@ func NewParser(l *Lexer) *Parser {
 	p.registerPrefix(TokenMinus, p.parsePrefixExpression)
 	p.registerPrefix(TokenLBrace, p.parseArrayLiteral)
 	p.registerPrefix(TokenLBracket, p.parseArraySizeLiteral)
+	p.registerPrefix(TokenFRGFn, p.parseFunctionLiteral)
 
 	p.infixParseFns = make(map[TokenType]infixParseFn)
 
@@ -683,6 +992,7 @@ func NewParser(l *Lexer) *Parser {
 	p.registerInfix(TokenGreaterEqual, p.parseInfixExpression)
 	p.registerInfix(TokenLBracket, p.parseIndexExpression)
 	p.registerInfix(TokenLParen, p.parseCallExpression)
+	p.registerInfix(TokenDot, p.parseMemberExpression)
 
 	p.nextToken()
 	p.nextToken()
@@ -699,19 +1009,152 @@ func (p *Parser) registerInfix(tokenType TokenType, fn infixParseFn) {
 }
 
 func (p *Parser) nextToken() {
+	p.previousToken = p.currentToken
 	p.currentToken = p.peekToken
+	if len(p.pending) > 0 {
+		p.peekToken = p.pending[0]
+		p.pending = p.pending[1:]
+		return
+	}
 	p.peekToken = p.lexer.NextToken()
 }
 
+// backUp undoes the last nextToken, only one step is possible
+func (p *Parser) backUp() {
+	p.pending = append([]Token{p.peekToken}, p.pending...)
+	p.peekToken = p.currentToken
+	p.currentToken = p.previousToken
+}
+
+// Errors returns the parsing errors as one line strings (file:line:col: error[code]: message)
 func (p *Parser) Errors() []string {
+	msgs := make([]string, len(p.errors))
+	for i, d := range p.errors {
+		msgs[i] = d.String()
+	}
+	return msgs
+}
+
+// Diagnostics returns the parsing errors with their positions
+func (p *Parser) Diagnostics() []Diagnostic {
 	return p.errors
 }
 
+// errorAt records a parsing error spanning tok
+// errors are dropped while the parser is skipping a broken statement
+func (p *Parser) errorAt(tok Token, code, format string, a ...interface{}) {
+	if p.panicking {
+		return
+	}
+	p.panicking = true
+	d := newDiagnostic(SeverityError, tok, code, format, a...)
+	if n := len(p.errors); n > 0 {
+		// nested blocks missing their End all fail on the same token
+		last := p.errors[n-1]
+		if last.File == d.File && last.Line == d.Line && last.Column == d.Column {
+			return
+		}
+	}
+	p.errors = append(p.errors, d)
+}
+
 func (p *Parser) peekError(t TokenType) {
-	msg := fmt.Sprintf(
-		"ERROR: expected next token to be %s, got %s instead (line %d, col %d)",
-		TokenToString(t), TokenToString(p.peekToken.Type), p.peekToken.Line, p.peekToken.Column)
-	p.errors = append(p.errors, msg)
+	if t == TokenHash && p.peekToken.Line > p.currentToken.Line {
+		// the # is usually forgotten at the end of the line, point there
+		// rather than at the first token of the next line
+		end := Token{Type: TokenHash, Literal: "#", Line: p.currentToken.Line, Column: tokenEnd(p.currentToken), File: p.currentToken.File}
+		p.errorAt(end, "expected-token", "missing # at the end of the statement")
+		return
+	}
+	p.errorAt(p.peekToken, "expected-token",
+		"expected next token to be %s, got %s instead", TokenToString(t), TokenToString(p.peekToken.Type))
+}
+
+// tokens a broken statement is skipped up to, they start or close a statement
+var synchronizeTokens = map[TokenType]bool{
+	TokenFRGInt:    true,
+	TokenFRGReal:   true,
+	TokenFRGStrg:   true,
+	TokenFRGPrint:  true,
+	TokenFRGInput:  true,
+	TokenFRGUse:    true,
+	TokenFRGExport: true,
+	TokenFRGStatic: true,
+	TokenFRGConst:  true,
+	TokenFRGEnum:   true,
+	TokenFRGFn:     true,
+	TokenIf:        true,
+	TokenRepeat:    true,
+	TokenBegin:     true,
+	TokenBreak:     true,
+	TokenContinue:  true,
+	TokenAssert:    true,
+	TokenEnd:       true,
+	TokenUntil:     true,
+	TokenFRGEnd:    true,
+	TokenEOF:       true,
+}
+
+// synchronize skips the rest of a broken statement so the parser can go on with the next one
+// it stops on the # ending the statement or just before a token starting or closing a statement
+// (or an identifier at the start of a line), the current token is left on the last skipped token
+func (p *Parser) synchronize() {
+	p.panicking = false
+
+	// the statement swallowed the End/Until of its block, give it back
+	closer := p.currentTokenIs(TokenFRGEnd) ||
+		(p.currentTokenIs(TokenEnd) && p.openBlocks+p.openRepeats > 0) ||
+		(p.currentTokenIs(TokenUntil) && p.openRepeats > 0)
+	if closer && p.currentToken != p.statementStart {
+		p.backUp()
+		return
+	}
+
+	for !p.currentTokenIs(TokenHash) && !p.currentTokenIs(TokenEOF) {
+		if synchronizeTokens[p.peekToken.Type] {
+			return
+		}
+		if p.peekTokenIs(TokenIdentifier) && p.peekToken.Line > p.currentToken.Line {
+			return
+		}
+		p.nextToken()
+	}
+}
+
+// parseStatements parses statements until the next token is one of closers, FRG_End or EOF
+// it returns with the current token on the last token of the last statement
+func (p *Parser) parseStatements(closers ...TokenType) []Statement {
+	statements := []Statement{}
+	for !p.peekTokenIs(TokenFRGEnd) && !p.peekTokenIs(TokenEOF) && !p.peekTokenIsOneOf(closers) {
+		p.nextToken()
+		p.statementStart = p.currentToken
+		stmt := p.parseStatement()
+		if stmt != nil {
+			statements = append(statements, stmt)
+		}
+		if p.panicking {
+			p.synchronize()
+		}
+	}
+	return statements
+}
+
+// DisableIncludes makes FRG_Use an error instead of reading the file (sandboxed programs)
+func (p *Parser) DisableIncludes() {
+	p.noIncludes = true
+}
+
+// AddSearchPath adds directories where FRG_Use looks for files (the -I flags),
+// they come before the ones of $FRG_PATH
+func (p *Parser) AddSearchPath(dirs ...string) {
+	p.moduleLoader().searchPath = append(append([]string{}, dirs...), p.moduleLoader().searchPath...)
+}
+
+func (p *Parser) moduleLoader() *moduleLoader {
+	if p.loader == nil {
+		p.loader = newModuleLoader()
+	}
+	return p.loader
 }
 
 func (p *Parser) IsThereAnyErrors() bool {
@@ -731,35 +1174,23 @@ func (p *Parser) ParseProgram() *Program {
 	program.Statements = []Statement{}
 
 	// frog code must start with FRG_Begin
-	if !p.expectCurrent(TokenFRGBegin) {
-		p.errors = append(p.errors, "program must start with FRG_Begin")
+	if !p.currentTokenIs(TokenFRGBegin) {
+		p.errorAt(p.currentToken, "missing-begin", "program must start with FRG_Begin, got %s", TokenToString(p.currentToken.Type))
 		return program
 	}
 
-	// escape FRG_Begin
-	p.nextToken()
-
-	// end used to detect if FRG_End token are at the end of the frog file or not
-	var end bool = false
-
-	for !p.currentTokenIs(TokenEOF) {
-		if p.currentTokenIs(TokenFRGEnd) {
-			// parsed successfuly and get FRG_End Token
-			end = true
-			break
-		}
-		// call private Parser method that parse the statements
-		stmt := p.parseStatement()
-		if stmt != nil {
-			program.Statements = append(program.Statements, stmt)
-		}
-		p.nextToken()
-	}
+	// parse everything up to FRG_End, broken statements are skipped
+	// so every mistake of the file gets reported
+	program.Statements = p.parseStatements()
+	p.checkConstants(program)
 
 	// checked :)
-	if !end {
-		p.errors = append(p.errors, "program must end with FRG_End")
+	if !p.peekTokenIs(TokenFRGEnd) {
+		p.panicking = false
+		p.errorAt(p.peekToken, "missing-end", "program must end with FRG_End")
+		return program
 	}
+	p.nextToken()
 
 	return program
 }
@@ -792,13 +1223,24 @@ func (p *Parser) parseStatement() Statement {
 		// DONE
 		return p.parseContinueStatement()
 	case TokenFRGUse:
-		return p.parseUseStatementAndInclude()
+		return p.parseUseStatement()
+	case TokenFRGExport:
+		return p.parseExportStatement()
+	case TokenFRGStatic:
+		return p.parseStaticStatement()
+	case TokenFRGConst:
+		return p.parseConstStatement()
+	case TokenFRGEnum:
+		return p.parseEnumStatement()
 	case TokenFRGFn:
 		return p.parseFunctionDeclarationStatement()
 	case TokenFRGInput:
 		return p.parseInputStatement()
+	case TokenAssert:
+		return p.parseAssertStatement()
 	case TokenIdentifier:
 		// (2*x+1 / 2)
+		first := p.currentToken
 		expr := p.parseExpression(LOWEST)
 		// parse identifier function (parseIdentifier) implement Expression interface
 		// we used parseExpression and it will called this method
@@ -814,44 +1256,33 @@ func (p *Parser) parseStatement() Statement {
 			}
 			return stmt
 		} else {
-			if ident, ok := expr.(*Identifier); ok {
-				if ident.Value == "if" {
-					msg := fmt.Sprintf("ERROR: syntax error, did you mean 'If'? (line %d, col %d)", ident.Token.Line, ident.Token.Column)
-					p.errors = append(p.errors, msg)
-					return nil
-				} else if ident.Value == "else" {
-					msg := fmt.Sprintf("ERROR: syntax error, did you mean 'Else'? (line %d, col %d)", ident.Token.Line, ident.Token.Column)
-					p.errors = append(p.errors, msg)
-					return nil
-				} else if ident.Value == "repeat" {
-					msg := fmt.Sprintf("ERROR: syntax error, did you mean 'Repeat'? (line %d, col %d)", ident.Token.Line, ident.Token.Column)
-					p.errors = append(p.errors, msg)
-					return nil
-				} else if ident.Value == "until" {
-					msg := fmt.Sprintf("ERROR: syntax error, did you mean 'Until'? (line %d, col %d)", ident.Token.Line, ident.Token.Column)
-					p.errors = append(p.errors, msg)
-					return nil
-				} else if ident.Value == "begin" {
-					msg := fmt.Sprintf("ERROR: syntax error, did you mean 'Begin'? (line %d, col %d)", ident.Token.Line, ident.Token.Column)
-					p.errors = append(p.errors, msg)
-					return nil
-				} else if ident.Value == "end" {
-					msg := fmt.Sprintf("ERROR: syntax error, did you mean 'End'? (line %d, col %d)", ident.Token.Line, ident.Token.Column)
-					p.errors = append(p.errors, msg)
-					return nil
-				}
+			// "if [x] ..." parses as indexing a variable named if
+			_, bare := expr.(*Identifier)
+			if keyword, ok := miscasedKeywords[first.Literal]; ok && (bare || !p.peekTokenIs(TokenHash)) {
+				p.errorAt(first, "keyword-case", "syntax error, did you mean '%s'?", keyword)
+				return nil
 			}
 			if !p.expectPeek(TokenHash) {
 				return nil
 			}
-			return &ExpressionStatement{Expression: expr}
+			return &ExpressionStatement{Token: first, Expression: expr}
 		}
 	}
-	msg := fmt.Sprintf("ERROR: Unexpected token '%s' at line %d, column %d. Cannot parse it as a statement.", p.currentToken.Literal, p.currentToken.Line, p.currentToken.Column)
-	p.errors = append(p.errors, msg)
+	p.errorAt(p.currentToken, "unexpected-token", "unexpected token '%s', cannot parse it as a statement", p.currentToken.Literal)
 	return nil
 }
 
+// lower case keywords students keep writing
+var miscasedKeywords = map[string]string{
+	"if":     "If",
+	"else":   "Else",
+	"repeat": "Repeat",
+	"until":  "Until",
+	"begin":  "Begin",
+	"end":    "End",
+	"assert": "Assert",
+}
+
 // parseDeclarationStatement parses variable declaration statements like "int x, y #".
 // This function handles both single and multiple variable declarations with optional array types.
 // The statement must end with a hash (#) token.
@@ -876,14 +1307,33 @@ func (p *Parser) parseDeclarationStatement() *DeclarationStatement {
 	}
 
 	stmt.Identifiers = append(stmt.Identifiers, &Identifier{Token: p.currentToken, Value: p.currentToken.Literal})
+	stmt.Values = append(stmt.Values, nil)
+	return p.parseDeclaredNames(stmt)
+}
 
-	for p.peekTokenIs(TokenComma) {
+// parseDeclaredNames reads "a := 10, b, c := a * 2#" from the first name,
+// already in stmt, to the #
+func (p *Parser) parseDeclaredNames(stmt *DeclarationStatement) *DeclarationStatement {
+	for {
+		if p.peekTokenIs(TokenAssign) {
+			p.nextToken()
+			p.nextToken()
+			value := p.parseExpression(LOWEST)
+			if isNilNode(value) {
+				return nil
+			}
+			stmt.Values[len(stmt.Values)-1] = value
+		}
+		if !p.peekTokenIs(TokenComma) {
+			break
+		}
 		// start iterating all ids
 		p.nextToken()
 		if !p.expectPeek(TokenIdentifier) {
 			return nil
 		}
 		stmt.Identifiers = append(stmt.Identifiers, &Identifier{Token: p.currentToken, Value: p.currentToken.Literal})
+		stmt.Values = append(stmt.Values, nil)
 	}
 
 	if !p.expectPeek(TokenHash) { // must end with HASH(#)
@@ -897,66 +1347,206 @@ func (p *Parser) parseDeclarationStatement() *DeclarationStatement {
 // "fn functionName(paramType paramName, ...) : returnType Begin ... End".
 // This function handles parsing the function name, parameter list, return type,
 // and function body block.
-func (p *Parser) parseFunctionDeclarationStatement() *FunctionDeclarationStatement {
+func (p *Parser) parseFunctionDeclarationStatement() Statement {
 	stmt := &FunctionDeclarationStatement{Token: p.currentToken}
 
+	if p.peekTokenIs(TokenLBracket) {
+		// FRG_Fn[] callbacks#
+		return p.parseDeclarationStatement()
+	}
 	if !p.expectPeek(TokenIdentifier) {
 		return nil
 	}
 	stmt.Name = &Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
 
+	if p.peekTokenIs(TokenHash) || p.peekTokenIs(TokenComma) || p.peekTokenIs(TokenAssign) {
+		// FRG_Fn f, g#  declares variables holding functions
+		return p.parseFunctionVariables(stmt)
+	}
+
 	if !p.expectPeek(TokenLParen) {
 		return nil
 	}
+	if !p.parseSignature(stmt) {
+		return nil
+	}
 
-	// Parse parameters
-	stmt.Parameters = []*Parameter{}
-	if !p.peekTokenIs(TokenRParen) {
-		p.nextToken()
-		for {
-			param := &Parameter{}
-			if !p.currentTokenIs(TokenFRGInt) && !p.currentTokenIs(TokenFRGReal) && !p.currentTokenIs(TokenFRGStrg) {
-				p.errors = append(p.errors, fmt.Sprintf("expected parameter type, got %s", p.currentToken.Literal))
-				return nil
-			}
-			param.Type = p.currentToken
+	if !p.expectPeek(TokenBegin) {
+		return nil
+	}
+	p.openFunctions = append(p.openFunctions, stmt)
+	stmt.Body = p.parseBlockStatement()
+	p.openFunctions = p.openFunctions[:len(p.openFunctions)-1]
 
-			if !p.expectPeek(TokenIdentifier) {
-				return nil
-			}
-			param.Name = &Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+	return stmt
+}
 
-			stmt.Parameters = append(stmt.Parameters, param)
+// parseFunctionVariables finishes "FRG_Fn f, g#" once the first name is read
+func (p *Parser) parseFunctionVariables(fn *FunctionDeclarationStatement) *DeclarationStatement {
+	stmt := &DeclarationStatement{Token: fn.Token, Identifiers: []*Identifier{fn.Name}, Values: []Expression{nil}}
+	return p.parseDeclaredNames(stmt)
+}
 
-			if p.peekTokenIs(TokenRParen) {
-				break
-			}
-			if !p.expectPeek(TokenComma) {
-				return nil
-			}
+// parseSignature parses the parameters and the return type, from the ( to the type
+func (p *Parser) parseSignature(stmt *FunctionDeclarationStatement) bool {
+	stmt.Parameters = []*Parameter{}
+	if !p.parseParameters(stmt) {
+		stmt.brokenParameters = true
+		// skip the rest of the list and keep checking the declaration
+		for !p.peekTokenIs(TokenRParen) && !p.peekTokenIs(TokenColon) && !p.peekTokenIs(TokenBegin) &&
+			!p.peekTokenIs(TokenHash) && !p.peekTokenIs(TokenEnd) && !p.peekTokenIs(TokenFRGEnd) && !p.peekTokenIs(TokenEOF) {
 			p.nextToken()
 		}
+		p.panicking = false
 	}
 
 	if !p.expectPeek(TokenRParen) {
-		return nil
+		return false
 	}
 
-	if !p.expectPeek(TokenColon) {
-		return nil
+	if p.peekTokenIs(TokenBegin) {
+		// no return type is the same as : FRG_Void
+		stmt.ReturnType = p.currentToken
+		stmt.ReturnType.Type, stmt.ReturnType.Literal = TokenFRGVoid, "FRG_Void"
+		return true
+	}
+	if !p.peekTokenIs(TokenColon) {
+		p.peekError(TokenColon)
+		if !isTypeToken(p.peekToken.Type) && !p.peekTokenIs(TokenFRGVoid) {
+			return false
+		}
+		// only the : is missing, the rest is still checked
+		p.panicking = false
+	} else {
+		p.nextToken()
 	}
 
 	p.nextToken()
-	if !p.currentTokenIs(TokenFRGInt) && !p.currentTokenIs(TokenFRGReal) && !p.currentTokenIs(TokenFRGStrg) {
-		p.errors = append(p.errors, fmt.Sprintf("expected return type, got %s", p.currentToken.Literal))
-		return nil
+	if p.currentToken.Type == TokenFRGVoid {
+		stmt.ReturnType = p.currentToken
+		return true
+	}
+	if !isTypeToken(p.currentToken.Type) {
+		p.errorAt(p.currentToken, "expected-type", "expected return type, got %s", p.currentToken.Literal)
+		return false
 	}
 	stmt.ReturnType = p.currentToken
+	var ok bool
+	stmt.ReturnsArray, ok = p.parseArrayMark()
+	return ok
+}
 
+// parseArrayMark reads the [] after the type of a parameter or a return value
+func (p *Parser) parseArrayMark() (isArray, ok bool) {
+	if !p.peekTokenIs(TokenLBracket) {
+		return false, true
+	}
 	p.nextToken()
-	stmt.Body = p.parseBlockStatement()
+	if !p.expectPeek(TokenRBracket) {
+		return false, false
+	}
+	return true, true
+}
 
-	return stmt
+// isTypeToken reports the types of parameters and return values
+func isTypeToken(t TokenType) bool {
+	return t == TokenFRGInt || t == TokenFRGReal || t == TokenFRGStrg || t == TokenFRGFn
+}
+
+// parseFunctionLiteral parses an anonymous function, the body gives its value to Result:
+//
+//	FRG_Fn(FRG_Int a, FRG_Int b) : FRG_Int Begin Result := a + b# End
+//	FRG_Fn(FRG_Int a, FRG_Int b) : FRG_Int := a + b
+func (p *Parser) parseFunctionLiteral() Expression {
+	lit := &FunctionLiteral{Token: p.currentToken}
+	fn := &FunctionDeclarationStatement{Token: p.currentToken, Name: &Identifier{Token: p.currentToken, Value: AnonymousResult}}
+	lit.Function = fn
+
+	if !p.expectPeek(TokenLParen) {
+		return nil
+	}
+	if !p.parseSignature(fn) {
+		return nil
+	}
+
+	if p.peekTokenIs(TokenAssign) {
+		p.nextToken()
+		if fn.Void() {
+			p.errorAt(p.currentToken, "void", "a FRG_Void function has no value to give with :=, use Begin ... End")
+			return nil
+		}
+		assign := &AssignmentStatement{Token: p.currentToken, Left: fn.Name}
+		p.nextToken()
+		assign.Value = p.parseExpression(LOWEST)
+		if isNilNode(assign.Value) {
+			return nil
+		}
+		fn.Body = &BlockStatement{Token: assign.Token, Statements: []Statement{assign}}
+		return lit
+	}
+	if !p.expectPeek(TokenBegin) {
+		return nil
+	}
+	p.openFunctions = append(p.openFunctions, fn)
+	fn.Body = p.parseBlockStatement()
+	p.openFunctions = p.openFunctions[:len(p.openFunctions)-1]
+	return lit
+}
+
+// parseParameters parses "FRG_Int a, FRG_Strg b" up to the token before the )
+func (p *Parser) parseParameters(stmt *FunctionDeclarationStatement) bool {
+	if p.peekTokenIs(TokenRParen) {
+		return true
+	}
+	p.nextToken()
+	for {
+		param := &Parameter{}
+		if !isTypeToken(p.currentToken.Type) {
+			p.errorAt(p.currentToken, "expected-type", "expected parameter type, got %s", p.currentToken.Literal)
+			return false
+		}
+		param.Type = p.currentToken
+		var ok bool
+		if param.IsArray, ok = p.parseArrayMark(); !ok {
+			return false
+		}
+		if p.peekTokenIs(TokenEllipsis) {
+			p.nextToken()
+			param.Variadic = true
+		}
+
+		if !p.expectPeek(TokenIdentifier) {
+			return false
+		}
+		param.Name = &Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+		if len(stmt.Parameters) > 0 && stmt.Parameters[len(stmt.Parameters)-1].Variadic {
+			p.errorAt(param.Name.Token, "variadic", "parameter %s comes after the variadic parameter, that one must be the last", param.Name.Value)
+		}
+		if param.Variadic && p.peekTokenIs(TokenAssign) {
+			p.errorAt(p.peekToken, "variadic", "variadic parameter %s cannot have a default value, it is an empty array", param.Name.Value)
+		}
+		if p.peekTokenIs(TokenAssign) {
+			p.nextToken()
+			p.nextToken()
+			param.Default = p.parseExpression(LOWEST)
+			if isNilNode(param.Default) {
+				return false
+			}
+		} else if !param.Variadic && len(stmt.Parameters) > 0 && stmt.Parameters[len(stmt.Parameters)-1].Default != nil {
+			// f(1) could not tell which one it gives
+			p.errorAt(param.Name.Token, "default", "parameter %s needs a default value, it follows a parameter with one", param.Name.Value)
+		}
+
+		stmt.Parameters = append(stmt.Parameters, param)
+
+		if p.peekTokenIs(TokenRParen) {
+			return true
+		}
+		if !p.expectPeek(TokenComma) {
+			return false
+		}
+		p.nextToken()
+	}
 }
 
 func (p *Parser) parseAssignmentStatement() *AssignmentStatement {
@@ -1023,44 +1613,262 @@ func (p *Parser) parsePrintStatement() *PrintStatement {
 	return stmt
 }
 
-func (p *Parser) parseUseStatementAndInclude() Statement {
-	useToken := p.currentToken // Store the FRG_Use token
+func (p *Parser) parseUseStatement() Statement {
+	stmt := &UseStatement{Token: p.currentToken}
+
+	// FRG_Use a, b From "file"#
+	if p.peekTokenIs(TokenIdentifier) {
+		p.nextToken()
+		stmt.Names = append(stmt.Names, &Identifier{Token: p.currentToken, Value: p.currentToken.Literal})
+		for p.peekTokenIs(TokenComma) {
+			p.nextToken()
+			if !p.expectPeek(TokenIdentifier) {
+				return nil
+			}
+			stmt.Names = append(stmt.Names, &Identifier{Token: p.currentToken, Value: p.currentToken.Literal})
+		}
+		if !p.expectPeek(TokenFrom) {
+			return nil
+		}
+	}
 
 	if !p.expectPeek(TokenString) {
 		return nil
 	}
-	filename := p.currentToken.Literal
+	stmt.Filename = &StringLiteral{Token: p.currentToken, Value: p.currentToken.Literal}
+
+	// FRG_Use "file" As name#
+	if len(stmt.Names) == 0 {
+		if p.peekTokenIs(TokenAs) {
+			p.nextToken()
+			if !p.expectPeek(TokenIdentifier) {
+				return nil
+			}
+			stmt.Alias = &Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+		} else {
+			name := moduleName(stmt.Filename.Value)
+			if name == "" {
+				p.errorAt(stmt.Filename.Token, "module-name", "%q is not a valid module name, add As <name>", stmt.Filename.Value)
+				return nil
+			}
+			stmt.Alias = &Identifier{Token: stmt.Filename.Token, Value: name}
+		}
+	}
 
 	if !p.expectPeek(TokenHash) {
 		return nil
 	}
 
-	// Read the content of the included file
-	content, err := os.ReadFile(filename)
+	if p.noIncludes {
+		p.errorAt(stmt.Token, "sandbox", "FRG_Use is not allowed in the sandbox")
+		return nil
+	}
+
+	stmt.Path, stmt.Module = p.parseModule(stmt.Filename)
+	if stmt.Module == nil {
+		return nil
+	}
+	return stmt
+}
+
+// parseModule reads and parses a file used by FRG_Use, once per program
+func (p *Parser) parseModule(filename *StringLiteral) (string, *Program) {
+	loader := p.moduleLoader()
+	from := filename.Token.File
+	if len(loader.chain) == 0 {
+		// the program itself, a module using it is a cycle too
+		loader.chain = append(loader.chain, moduleRef{moduleKey(from), from})
+	}
+
+	file, key, err := loader.resolve(from, filename.Value)
 	if err != nil {
-		p.errors = append(p.errors, fmt.Sprintf("ERROR: could not read included file %s: %v", filename, err))
+		p.errorAt(filename.Token, "include-error", "%v", err)
+		return "", nil
+	}
+	if cycle := loader.enter(moduleRef{key, file}); cycle != nil {
+		p.errorAt(filename.Token, "import-cycle", "import cycle: %s", strings.Join(cycle, " -> "))
+		return key, nil
+	}
+	defer loader.leave()
+	if program, ok := loader.programs[key]; ok {
+		return key, program
+	}
+
+	content, err := ReadSource(file)
+	if err != nil {
+		p.errorAt(filename.Token, "include-error", "could not read included file %s: %v", file, err)
+		return key, nil
+	}
+
+	parser := NewParser(NewFileLexer(file, string(content)))
+	parser.loader = loader
+	parser.noIncludes = p.noIncludes
+	program := parser.ParseProgram()
+	if parser.IsThereAnyErrors() {
+		// their positions point into the included file
+		p.errors = append(p.errors, parser.Diagnostics()...)
+		program = nil
+	}
+	loader.programs[key] = program
+	return key, program
+}
+
+// moduleName is the name a module gets without As: the file name without directory and extension
+func moduleName(filename string) string {
+	name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	if name == "" {
+		return ""
+	}
+	for i, ch := range name {
+		if !isLetter(ch) && (i == 0 || !isDigit(ch)) {
+			return ""
+		}
+	}
+	if _, ok := keywords[name]; ok {
+		return ""
+	}
+	return name
+}
+
+// parseExportStatement parses FRG_Export followed by a declaration or a function
+func (p *Parser) parseExportStatement() Statement {
+	stmt := &ExportStatement{Token: p.currentToken}
+	if p.openBlocks+p.openRepeats > 0 {
+		p.errorAt(stmt.Token, "export", "FRG_Export is only allowed at the top level of a file")
+		return nil
+	}
+	switch p.peekToken.Type {
+	case TokenFRGInt, TokenFRGReal, TokenFRGStrg, TokenFRGFn, TokenFRGConst, TokenFRGEnum:
+	default:
+		p.errorAt(p.peekToken, "export", "FRG_Export must be followed by a declaration or a FRG_Fn, got %s", TokenToString(p.peekToken.Type))
+		return nil
+	}
+	p.nextToken()
+	stmt.Statement = p.parseStatement()
+	if isNilNode(stmt.Statement) {
 		return nil
 	}
+	return stmt
+}
 
-	// Create a new lexer and parser for the included file
-	includedLexer := NewLexer(string(content))
-	includedParser := NewParser(includedLexer)
+// parseConstStatement parses FRG_Const FRG_Int A := 1, B := 2#
+func (p *Parser) parseConstStatement() Statement {
+	stmt := &ConstStatement{Token: p.currentToken}
+	if !isTypeToken(p.peekToken.Type) {
+		p.errorAt(p.peekToken, "const", "FRG_Const must be followed by a type, got %s", TokenToString(p.peekToken.Type))
+		return nil
+	}
+	p.nextToken()
+	stmt.Type = p.currentToken
+	var ok bool
+	if stmt.IsArray, ok = p.parseArrayMark(); !ok {
+		return nil
+	}
+	for {
+		if !p.expectPeek(TokenIdentifier) {
+			return nil
+		}
+		name := &Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+		if !p.peekTokenIs(TokenAssign) {
+			p.errorAt(name.Token, "const", "FRG_Const %s needs a value: %s := ...", name.Value, name.Value)
+			return nil
+		}
+		p.nextToken()
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+		if isNilNode(value) {
+			return nil
+		}
+		stmt.Names = append(stmt.Names, name)
+		stmt.Values = append(stmt.Values, value)
+		if !p.peekTokenIs(TokenComma) {
+			break
+		}
+		p.nextToken()
+	}
+	if !p.expectPeek(TokenHash) {
+		return nil
+	}
+	return stmt
+}
 
-	// Parse the included file's program
-	includedProgram := includedParser.ParseProgram()
+// parseEnumStatement parses FRG_Enum Color Begin Red, Green, Blue End
+func (p *Parser) parseEnumStatement() Statement {
+	stmt := &EnumStatement{Token: p.currentToken}
+	if !p.expectPeek(TokenIdentifier) {
+		return nil
+	}
+	stmt.Name = &Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+	if !p.expectPeek(TokenBegin) {
+		return nil
+	}
+	for !p.peekTokenIs(TokenEnd) {
+		if len(stmt.Members) > 0 && !p.expectPeek(TokenComma) {
+			return nil
+		}
+		if !p.expectPeek(TokenIdentifier) {
+			return nil
+		}
+		stmt.Members = append(stmt.Members, &Identifier{Token: p.currentToken, Value: p.currentToken.Literal})
+	}
+	p.nextToken()
 
-	if includedParser.IsThereAnyErrors() {
-		for _, err := range includedParser.Errors() {
-			p.errors = append(p.errors, fmt.Sprintf("ERROR in included file %s: %s", filename, err))
+	// the whole declaration is read, these mistakes do not need the parser to resynchronize
+	failed := false
+	report := func(tok Token, format string, args ...interface{}) {
+		p.errorAt(tok, "enum", format, args...)
+		p.panicking = false
+		failed = true
+	}
+	if p.openBlocks+p.openRepeats > 0 {
+		report(stmt.Token, "FRG_Enum is only allowed at the top level of a file")
+	}
+	if len(stmt.Members) == 0 {
+		report(p.currentToken, "FRG_Enum %s needs at least one member", stmt.Name.Value)
+	}
+	seen := make(map[string]bool)
+	for _, member := range stmt.Members {
+		if seen[member.Value] {
+			report(member.Token, "%s is already a member of %s", member.Value, stmt.Name.Value)
 		}
+		seen[member.Value] = true
+	}
+	if failed {
 		return nil
 	}
+	return stmt
+}
 
-	// Return a BlockStatement containing all statements from the included file
-	// This effectively "inlines" the included file's statements into the current AST.
-	block := &BlockStatement{Token: useToken} // Use the TokenFRGUse token for the block
-	block.Statements = includedProgram.Statements
-	return block
+// parseStaticStatement parses FRG_Static followed by a declaration
+func (p *Parser) parseStaticStatement() Statement {
+	stmt := &StaticStatement{Token: p.currentToken}
+	if len(p.openFunctions) == 0 {
+		p.errorAt(stmt.Token, "static", "FRG_Static is only allowed inside a FRG_Fn")
+		return nil
+	}
+	switch p.peekToken.Type {
+	case TokenFRGInt, TokenFRGReal, TokenFRGStrg:
+	default:
+		p.errorAt(p.peekToken, "static", "FRG_Static must be followed by a declaration, got %s", TokenToString(p.peekToken.Type))
+		return nil
+	}
+	p.nextToken()
+	stmt.Declaration = p.parseDeclarationStatement()
+	if stmt.Declaration == nil {
+		return nil
+	}
+	fn := p.openFunctions[len(p.openFunctions)-1]
+	result, name := fn.Name.Value, fn.Name.Value
+	if fn.Anonymous() {
+		result, name = AnonymousResult, "the anonymous FRG_Fn"
+	}
+	for _, ident := range stmt.Declaration.Identifiers {
+		if conflict := staticConflict(ident.Value, result, fn.Parameters); conflict != "" {
+			p.errorAt(ident.Token, "static", "FRG_Static %s cannot have the name of %s of %s", ident.Value, conflict, name)
+			return nil
+		}
+	}
+	return stmt
 }
 
 // parseIfStatement parses if statements in the form "If [condition] statement [Else statement]".
@@ -1094,26 +1902,48 @@ func (p *Parser) parseIfStatement() *IfStatement {
 	return stmt
 }
 
-func (p *Parser) parseRepeatStatement() *RepeatStatement {
-	stmt := &RepeatStatement{Token: p.currentToken}
-	stmt.Body = []Statement{}
+func (p *Parser) parseAssertStatement() *AssertStatement {
+	stmt := &AssertStatement{Token: p.currentToken}
 
-	p.nextToken() // kill Repeat Token
+	if !p.expectPeek(TokenLBracket) { // [
+		return nil
+	}
+	p.nextToken() // kill [
+	stmt.Condition = p.parseExpression(LOWEST)
+	if !p.expectPeek(TokenRBracket) { // ]
+		return nil
+	}
 
-	for !p.currentTokenIs(TokenUntil) && !p.currentTokenIs(TokenEOF) {
-		// keep parsing body
-		s := p.parseStatement()
-		if s != nil {
-			stmt.Body = append(stmt.Body, s)
-		}
+	// the message is optional
+	if !p.peekTokenIs(TokenHash) {
 		p.nextToken()
+		stmt.Message = p.parseExpression(LOWEST)
+	}
+
+	if !p.expectPeek(TokenHash) {
+		return nil
 	}
+	return stmt
+}
+
+func (p *Parser) parseRepeatStatement() *RepeatStatement {
+	stmt := &RepeatStatement{Token: p.currentToken}
+
+	// keep parsing body, an End means the Until was forgotten
+	// and the End belongs to the block around the Repeat
+	p.openRepeats++
+	stmt.Body = p.parseStatements(TokenUntil, TokenEnd)
+	p.openRepeats--
 
-	// check if current token is Until if not web reach to the eof and that's error
-	if !p.currentTokenIs(TokenUntil) {
-		p.peekError(TokenUntil)
+	if !p.peekTokenIs(TokenUntil) {
+		p.errorAt(p.peekToken, "expected-token", "expected Until to close the Repeat of line %d, got %s", stmt.Token.Line, TokenToString(p.peekToken.Type))
+		if p.peekTokenIs(TokenEnd) && p.openBlocks == 0 {
+			// no block to close, the End was written instead of Until
+			p.nextToken()
+		}
 		return nil
 	}
+	p.nextToken() // Until
 
 	if !p.expectPeek(TokenLBracket) { // [
 		return nil
@@ -1128,22 +1958,17 @@ func (p *Parser) parseRepeatStatement() *RepeatStatement {
 
 func (p *Parser) parseBlockStatement() *BlockStatement {
 	block := &BlockStatement{Token: p.currentToken}
-	block.Statements = []Statement{}
 
-	p.nextToken() // kill Begin Token
+	// keep parsing the body
+	p.openBlocks++
+	block.Statements = p.parseStatements(TokenEnd)
+	p.openBlocks--
 
-	for !p.currentTokenIs(TokenEnd) && !p.currentTokenIs(TokenEOF) {
-		// keep parsing the body
-		stmt := p.parseStatement()
-		if stmt != nil {
-			block.Statements = append(block.Statements, stmt)
-		}
-		p.nextToken()
-	}
-
-	if !p.currentTokenIs(TokenEnd) { // if it's not token end that means we reach to the eof and that's error
-		p.errors = append(p.errors, fmt.Sprintf("unterminated block statement, expected End, got %s", TokenToString(p.currentToken.Type)))
+	if !p.peekTokenIs(TokenEnd) { // if it's not token end that means we reach to FRG_End or the eof and that's error
+		p.errorAt(p.peekToken, "unterminated-block", "unterminated block statement of line %d, expected End, got %s", block.Token.Line, TokenToString(p.peekToken.Type))
+		return block
 	}
+	p.nextToken() // End
 
 	return block
 }
@@ -1291,8 +2116,7 @@ func (p *Parser) parseNumberLiteral() Expression {
 		lit := &RealLiteral{Token: p.currentToken}
 		value, err := strconv.ParseFloat(p.currentToken.Literal, 64)
 		if err != nil {
-			msg := fmt.Sprintf("could not parse %q as float", p.currentToken.Literal)
-			p.errors = append(p.errors, msg)
+			p.errorAt(p.currentToken, "invalid-number", "could not parse %q as float", p.currentToken.Literal)
 			return nil
 		}
 		lit.Value = value
@@ -1302,8 +2126,7 @@ func (p *Parser) parseNumberLiteral() Expression {
 	lit := &IntegerLiteral{Token: p.currentToken}
 	value, err := strconv.ParseInt(p.currentToken.Literal, 0, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.currentToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.errorAt(p.currentToken, "invalid-number", "could not parse %q as integer", p.currentToken.Literal)
 		return nil
 	}
 	lit.Value = value
@@ -1354,11 +2177,12 @@ func (p *Parser) peekTokenIs(t TokenType) bool {
 	return p.peekToken.Type == t
 }
 
-func (p *Parser) expectCurrent(t TokenType) bool {
-	if p.currentTokenIs(t) {
-		return true
+func (p *Parser) peekTokenIsOneOf(types []TokenType) bool {
+	for _, t := range types {
+		if p.peekTokenIs(t) {
+			return true
+		}
 	}
-	p.errors = append(p.errors, fmt.Sprintf("expected current token to be %s, got %s instead", TokenToString(t), TokenToString(p.currentToken.Type)))
 	return false
 }
 
@@ -1440,12 +2264,65 @@ func (p *Parser) parseIndexExpression(left Expression) Expression {
 	return exp
 }
 
+func (p *Parser) parseMemberExpression(object Expression) Expression {
+	exp := &MemberExpression{Token: p.currentToken, Object: object}
+	if !p.expectPeek(TokenIdentifier) {
+		return nil
+	}
+	exp.Member = &Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+	return exp
+}
+
 func (p *Parser) parseCallExpression(function Expression) Expression {
 	exp := &CallExpression{Token: p.currentToken, Function: function}
-	exp.Arguments = p.parseExpressionList(TokenRParen)
+	exp.Arguments = p.parseCallArguments()
 	return exp
 }
 
+// parseCallArguments parses f(1, 2, color := "red"), the named arguments come
+// after the positional ones and only once each
+func (p *Parser) parseCallArguments() []Expression {
+	list := []Expression{}
+	if p.peekTokenIs(TokenRParen) {
+		p.nextToken()
+		return list
+	}
+	named := make(map[string]bool)
+	for {
+		p.nextToken()
+		if p.currentTokenIs(TokenIdentifier) && p.peekTokenIs(TokenAssign) {
+			arg := &NamedArgument{Name: &Identifier{Token: p.currentToken, Value: p.currentToken.Literal}}
+			p.nextToken()
+			arg.Token = p.currentToken
+			p.nextToken()
+			arg.Value = p.parseExpression(LOWEST)
+			if named[arg.Name.Value] {
+				p.errorAt(arg.Name.Token, "named-argument", "argument %s is given twice", arg.Name.Value)
+			}
+			named[arg.Name.Value] = true
+			list = append(list, arg)
+		} else {
+			arg := p.parseExpression(LOWEST)
+			if len(named) > 0 && !isNilNode(arg) {
+				p.errorAt(startToken(arg), "named-argument", "positional argument after named arguments")
+			}
+			if p.peekTokenIs(TokenEllipsis) {
+				p.nextToken()
+				arg = &SpreadArgument{Token: p.currentToken, Value: arg}
+			}
+			list = append(list, arg)
+		}
+		if !p.peekTokenIs(TokenComma) {
+			break
+		}
+		p.nextToken()
+	}
+	if !p.expectPeek(TokenRParen) {
+		return nil
+	}
+	return list
+}
+
 func (p *Parser) parseExpressionList(end TokenType) []Expression {
 	list := []Expression{}
 	if p.peekTokenIs(end) {
@@ -1466,6 +2343,9 @@ func (p *Parser) parseExpressionList(end TokenType) []Expression {
 }
 
 func (p *Parser) noPrefixParseFnError(t TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found (line %d, col %d)", TokenToString(t), p.currentToken.Line, p.currentToken.Column)
-	p.errors = append(p.errors, msg)
+	if t == TokenIllegal && strings.HasPrefix(p.currentToken.Literal, "\"") {
+		p.errorAt(p.currentToken, "unterminated-string", "string not closed, the file ends before its closing \"")
+		return
+	}
+	p.errorAt(p.currentToken, "expected-expression", "expected an expression, got %s", TokenToString(t))
 }
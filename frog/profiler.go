@@ -0,0 +1,406 @@
+// Copyright (C) by abdenour souane
+// you have a right to modify it upgrade it or do whatever you want
+// but u have to keep my name on it
+
+package frog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// the profiler is told about every statement and every FRG_Fn call by the runtime
+// it counts the statements run per line, the time spent on them (the time until the next one)
+// and the time spent in every function, the same samples are written in the pprof format
+// so `go tool pprof` can show the frog functions and lines
+
+// the top level of the program is shown as this function
+const profileMain = "FRG_Begin"
+
+type profileLocation struct {
+	function string
+	file     string
+	line     int
+}
+
+// profileSample is a call stack with what was measured for it
+type profileSample struct {
+	stack []profileLocation // innermost first
+	count int64
+	nanos int64
+}
+
+type profileFunction struct {
+	name   string
+	calls  int64
+	total  time.Duration // including the functions it calls
+	self   time.Duration
+	active int // calls in progress, a recursion is timed once
+	start  time.Time
+}
+
+type Profiler struct {
+	start time.Time
+	end   time.Time
+
+	lines     map[lineKey]*profileSample // per source line, stack ignored
+	samples   map[string]*profileSample  // per call stack
+	functions map[string]*profileFunction
+
+	// the statement running now, it gets the time until the next one
+	last     *profileSample
+	lastLine *profileSample
+	lastFn   *profileFunction
+	lastTime time.Time
+
+	// the calling statements, they get the time again once the call returns
+	callers []profileCaller
+}
+
+type profileCaller struct {
+	sample, line *profileSample
+	fn           *profileFunction
+}
+
+func NewProfiler() *Profiler {
+	now := time.Now()
+	return &Profiler{
+		start:     now,
+		lastTime:  now,
+		lines:     make(map[lineKey]*profileSample),
+		samples:   make(map[string]*profileSample),
+		functions: make(map[string]*profileFunction),
+	}
+}
+
+// WithProfiler records the run in p
+func WithProfiler(p *Profiler) Option {
+	return func(rt *Runtime) {
+		rt.Profiler = p
+	}
+}
+
+// statement is called before every statement
+func (p *Profiler) statement(stmt Statement, frames []*Frame) {
+	p.flush(time.Now())
+
+	tok := startToken(stmt)
+	stack := make([]profileLocation, 0, len(frames)+1)
+	at := tok
+	for i := len(frames) - 1; i >= 0; i-- {
+		stack = append(stack, profileLocation{frames[i].Function.Name, at.File, at.Line})
+		at = startToken(frames[i].Call)
+	}
+	stack = append(stack, profileLocation{profileMain, at.File, at.Line})
+
+	var key strings.Builder
+	for _, loc := range stack {
+		fmt.Fprintf(&key, "%s@%s:%d;", loc.function, loc.file, loc.line)
+	}
+	sample, ok := p.samples[key.String()]
+	if !ok {
+		sample = &profileSample{stack: stack}
+		p.samples[key.String()] = sample
+	}
+	sample.count++
+
+	lk := lineKey{tok.File, tok.Line}
+	line, ok := p.lines[lk]
+	if !ok {
+		line = &profileSample{stack: stack[:1]}
+		p.lines[lk] = line
+	}
+	line.count++
+
+	p.last, p.lastLine = sample, line
+	p.lastFn = p.function(stack[0].function)
+}
+
+// flush gives the time since the last statement to it
+func (p *Profiler) flush(now time.Time) {
+	elapsed := now.Sub(p.lastTime)
+	p.lastTime = now
+	if p.last == nil {
+		return
+	}
+	p.last.nanos += int64(elapsed)
+	p.lastLine.nanos += int64(elapsed)
+	p.lastFn.self += elapsed
+}
+
+func (p *Profiler) function(name string) *profileFunction {
+	fn, ok := p.functions[name]
+	if !ok {
+		fn = &profileFunction{name: name}
+		p.functions[name] = fn
+	}
+	return fn
+}
+
+func (p *Profiler) enter(function *Function) {
+	p.flush(time.Now())
+	p.callers = append(p.callers, profileCaller{p.last, p.lastLine, p.lastFn})
+	fn := p.function(function.Name)
+	fn.calls++
+	if fn.active == 0 {
+		fn.start = time.Now()
+	}
+	fn.active++
+}
+
+func (p *Profiler) exit(function *Function) {
+	p.flush(time.Now())
+	caller := p.callers[len(p.callers)-1]
+	p.callers = p.callers[:len(p.callers)-1]
+	p.last, p.lastLine, p.lastFn = caller.sample, caller.line, caller.fn
+	fn := p.function(function.Name)
+	fn.active--
+	if fn.active == 0 {
+		fn.total += time.Since(fn.start)
+	}
+}
+
+// Stop ends the measure, call it when Eval returns
+func (p *Profiler) Stop() {
+	p.end = time.Now()
+	p.flush(p.end)
+	p.last = nil
+	main := p.function(profileMain)
+	main.calls = 1
+	main.total = p.end.Sub(p.start)
+}
+
+// WriteReport writes the top lines and functions of the profile
+func (p *Profiler) WriteReport(w io.Writer, top int) {
+	total := int64(0)
+	for _, line := range p.lines {
+		total += line.count
+	}
+	fmt.Fprintf(w, "frog profile: %d statements in %v\n\n", total, p.end.Sub(p.start).Round(time.Microsecond))
+
+	lines := make([]*profileSample, 0, len(p.lines))
+	for _, line := range p.lines {
+		lines = append(lines, line)
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].count != lines[j].count {
+			return lines[i].count > lines[j].count
+		}
+		a, b := lines[i].stack[0], lines[j].stack[0]
+		return a.file < b.file || (a.file == b.file && a.line < b.line)
+	})
+	fmt.Fprintf(w, "%10s %12s  %s\n", "count", "time", "line")
+	sources := make(map[string][]string)
+	for i, line := range lines {
+		if i == top {
+			break
+		}
+		loc := line.stack[0]
+		fmt.Fprintf(w, "%10d %12v  %s:%d  %s\n", line.count, time.Duration(line.nanos).Round(time.Microsecond),
+			loc.file, loc.line, sourceLine(sources, loc.file, loc.line))
+	}
+
+	functions := make([]*profileFunction, 0, len(p.functions))
+	for _, fn := range p.functions {
+		functions = append(functions, fn)
+	}
+	sort.Slice(functions, func(i, j int) bool {
+		if functions[i].total != functions[j].total {
+			return functions[i].total > functions[j].total
+		}
+		return functions[i].name < functions[j].name
+	})
+	fmt.Fprintf(w, "\n%10s %12s %12s  %s\n", "calls", "self", "total", "function")
+	for i, fn := range functions {
+		if i == top {
+			break
+		}
+		fmt.Fprintf(w, "%10d %12v %12v  %s\n", fn.calls, fn.self.Round(time.Microsecond), fn.total.Round(time.Microsecond), fn.name)
+	}
+}
+
+// sourceLine returns the trimmed text of a line, files are read once
+func sourceLine(sources map[string][]string, file string, line int) string {
+	lines, ok := sources[file]
+	if !ok {
+		if content, err := ReadSource(file); err == nil {
+			lines = strings.Split(string(content), "\n")
+		}
+		sources[file] = lines
+	}
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	return strings.TrimSpace(lines[line-1])
+}
+
+// WritePprof writes the samples as a gzipped pprof profile (profile.proto)
+// with two values per sample: statements run and nanoseconds spent
+func (p *Profiler) WritePprof(w io.Writer) error {
+	var b protoBuffer
+	strs := map[string]int64{"": 0}
+	table := []string{""}
+	str := func(s string) int64 {
+		if i, ok := strs[s]; ok {
+			return i
+		}
+		strs[s] = int64(len(table))
+		table = append(table, s)
+		return strs[s]
+	}
+
+	valueType := func(typ, unit string) []byte {
+		var m protoBuffer
+		m.int(1, str(typ))
+		m.int(2, str(unit))
+		return m.bytes()
+	}
+	b.message(1, valueType("statements", "count"))
+	b.message(1, valueType("time", "nanoseconds"))
+
+	// the same function and line is one location
+	functionIDs := make(map[[2]string]uint64)
+	locationIDs := make(map[profileLocation]uint64)
+	var functions, locations protoBuffer
+
+	keys := make([]string, 0, len(p.samples))
+	for key := range p.samples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		sample := p.samples[key]
+		ids := []uint64{}
+		for _, loc := range sample.stack {
+			id, ok := locationIDs[loc]
+			if !ok {
+				fnKey := [2]string{loc.function, loc.file}
+				fnID, ok := functionIDs[fnKey]
+				if !ok {
+					fnID = uint64(len(functionIDs) + 1)
+					functionIDs[fnKey] = fnID
+					var fn protoBuffer
+					fn.uint(1, fnID)
+					fn.int(2, str(loc.function))
+					fn.int(3, str(loc.function))
+					fn.int(4, str(loc.file))
+					functions.message(5, fn.bytes())
+				}
+				id = uint64(len(locationIDs) + 1)
+				locationIDs[loc] = id
+				var line protoBuffer
+				line.uint(1, fnID)
+				line.int(2, int64(loc.line))
+				var l protoBuffer
+				l.uint(1, id)
+				l.uint(2, 1) // mapping
+				l.uint(3, id)
+				l.message(4, line.bytes())
+				locations.message(4, l.bytes())
+			}
+			ids = append(ids, id)
+		}
+		var s protoBuffer
+		s.packedUint(1, ids)
+		s.packedInt(2, []int64{sample.count, sample.nanos})
+		b.message(2, s.bytes())
+	}
+
+	// a single fake mapping, the locations are already symbolized
+	var mapping protoBuffer
+	mapping.uint(1, 1)
+	mapping.uint(3, uint64(len(locationIDs)+1))
+	mapping.int(5, str("frog"))
+	mapping.bool(7, true)
+	mapping.bool(8, true)
+	b.message(3, mapping.bytes())
+
+	b.raw(locations.bytes())
+	b.raw(functions.bytes())
+	str(profileMain) // keep the table stable even without samples
+	for _, s := range table {
+		b.string(6, s)
+	}
+	b.int(9, p.start.UnixNano())
+	b.int(10, int64(p.end.Sub(p.start)))
+	b.message(11, valueType("time", "nanoseconds"))
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(b.bytes()); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// protoBuffer encodes the few protobuf wire types pprof needs
+type protoBuffer struct {
+	buf bytes.Buffer
+}
+
+func (b *protoBuffer) bytes() []byte {
+	return b.buf.Bytes()
+}
+
+func (b *protoBuffer) varint(x uint64) {
+	for x >= 0x80 {
+		b.buf.WriteByte(byte(x) | 0x80)
+		x >>= 7
+	}
+	b.buf.WriteByte(byte(x))
+}
+
+func (b *protoBuffer) key(field, wireType int) {
+	b.varint(uint64(field<<3 | wireType))
+}
+
+func (b *protoBuffer) uint(field int, x uint64) {
+	b.key(field, 0)
+	b.varint(x)
+}
+
+func (b *protoBuffer) int(field int, x int64) {
+	b.uint(field, uint64(x))
+}
+
+func (b *protoBuffer) bool(field int, x bool) {
+	if x {
+		b.uint(field, 1)
+	} else {
+		b.uint(field, 0)
+	}
+}
+
+func (b *protoBuffer) message(field int, data []byte) {
+	b.key(field, 2)
+	b.varint(uint64(len(data)))
+	b.buf.Write(data)
+}
+
+func (b *protoBuffer) string(field int, s string) {
+	b.message(field, []byte(s))
+}
+
+func (b *protoBuffer) raw(data []byte) {
+	b.buf.Write(data)
+}
+
+func (b *protoBuffer) packedUint(field int, xs []uint64) {
+	var p protoBuffer
+	for _, x := range xs {
+		p.varint(x)
+	}
+	b.message(field, p.bytes())
+}
+
+func (b *protoBuffer) packedInt(field int, xs []int64) {
+	var p protoBuffer
+	for _, x := range xs {
+		p.varint(uint64(x))
+	}
+	b.message(field, p.bytes())
+}
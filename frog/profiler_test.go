@@ -0,0 +1,147 @@
+// Copyright (C) by abdenour souane
+// you have a right to modify it upgrade it or do whatever you want
+// but u have to keep my name on it
+
+package frog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestProfilerExport(t *testing.T) {
+	program := parseSource(t, "export.frg", exportSource)
+	p := NewProfiler()
+	runProgram(t, program, WithProfiler(p))
+	p.Stop()
+	for _, line := range []int{2, 3, 5, 7} {
+		sample := p.lines[lineKey{"export.frg", line}]
+		if sample == nil || sample.count != 1 {
+			t.Errorf("export.frg:%d counted %v times, want once", line, sample)
+		}
+	}
+}
+
+// protoField is one field of a protobuf message, a varint or the bytes of a message
+type protoField struct {
+	num    int
+	varint uint64
+	data   []byte
+}
+
+// readProto splits a message in its fields, only the wire types WritePprof uses
+func readProto(t *testing.T, b []byte) []protoField {
+	t.Helper()
+	fields := []protoField{}
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		b = b[n:]
+		f := protoField{num: int(key >> 3)}
+		switch key & 7 {
+		case 0:
+			f.varint, n = binary.Uvarint(b)
+			b = b[n:]
+		case 2:
+			size, n := binary.Uvarint(b)
+			f.data, b = b[n:n+int(size)], b[n+int(size):]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+func readVarints(b []byte) []uint64 {
+	values := []uint64{}
+	for len(b) > 0 {
+		v, n := binary.Uvarint(b)
+		values = append(values, v)
+		b = b[n:]
+	}
+	return values
+}
+
+// the pprof profile is read back into "function file:line;caller file:line count" samples
+func TestProfilerPprof(t *testing.T) {
+	program := parseSource(t, "export.frg", exportSource)
+	p := NewProfiler()
+	runProgram(t, program, WithProfiler(p))
+	p.Stop()
+	var out bytes.Buffer
+	if err := p.WritePprof(&out); err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(&out)
+	if err != nil {
+		t.Fatalf("the profile is not gzipped: %v", err)
+	}
+	raw, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var strs []string
+	var types []string
+	functions := map[uint64]string{}
+	locations := map[uint64]string{}
+	var samples [][]uint64
+	fields := readProto(t, raw)
+	for _, f := range fields {
+		if f.num == 6 {
+			strs = append(strs, string(f.data))
+		}
+	}
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			vt := readProto(t, f.data)
+			types = append(types, strs[vt[0].varint]+"/"+strs[vt[1].varint])
+		case 2:
+			samples = append(samples, nil)
+			for _, sf := range readProto(t, f.data) {
+				samples[len(samples)-1] = append(samples[len(samples)-1], readVarints(sf.data)...)
+			}
+		case 5:
+			fn := readProto(t, f.data)
+			functions[fn[0].varint] = strs[fn[1].varint] + " " + strs[fn[3].varint]
+		}
+	}
+	for _, f := range fields {
+		if f.num == 4 {
+			loc := readProto(t, f.data)
+			line := readProto(t, loc[3].data)
+			locations[loc[0].varint] = fmt.Sprintf("%s:%d", functions[line[0].varint], line[1].varint)
+		}
+	}
+
+	if strings.Join(types, " ") != "statements/count time/nanoseconds" {
+		t.Errorf("sample types %v", types)
+	}
+	if len(strs) == 0 || strs[0] != "" {
+		t.Errorf("the string table must start with \"\", got %q", strs)
+	}
+	got := []string{}
+	for _, s := range samples {
+		// the location ids, then the count and the time
+		stack := []string{}
+		for _, id := range s[:len(s)-2] {
+			stack = append(stack, locations[id])
+		}
+		got = append(got, fmt.Sprintf("%s %d", strings.Join(stack, ";"), s[len(s)-2]))
+	}
+	want := []string{
+		"FRG_Begin export.frg:2 1",
+		"FRG_Begin export.frg:3 1",
+		"FRG_Begin export.frg:7 1",
+		"twice export.frg:5;FRG_Begin export.frg:7 1",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("samples\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright (C) by abdenour souane
+// you have a right to modify it upgrade it or do whatever you want
+// but u have to keep my name on it
+
+package frog
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+)
+
+// DefaultMaxCallDepth is the number of nested FRG_Fn calls allowed by default,
+// a deeper recursion stops the program with a stack overflow error
+const DefaultMaxCallDepth = 1000
+
+// Runtime is the state shared by every environment of one run of a program
+// (the global one and the one of every FRG_Fn call)
+type Runtime struct {
+	// Hook is called before each statement is evaluated (not for Begin ... End blocks)
+	// the debugger uses it to stop the program
+	Hook func(stmt Statement, env *Environment)
+
+	// Frames are the FRG_Fn calls in progress, the innermost is the last
+	Frames []*Frame
+
+	// In is where FRG_Input reads from and Out where FRG_Print writes
+	In  *bufio.Reader
+	Out io.Writer
+
+	// MaxCallDepth limits the nested FRG_Fn calls, 0 means no limit
+	MaxCallDepth int
+
+	// limits for untrusted programs, 0 means no limit
+	MaxSteps         int // executed statements (and Repeat iterations)
+	MaxArrayElements int // array elements allocated by the whole run
+	steps            int
+	elements         int64
+
+	// Context stops the program when it is canceled or its deadline passes,
+	// it is checked at every Repeat iteration and FRG_Fn call
+	Context context.Context
+
+	// Sandbox forbids FRG_Input and running FRG_Use files
+	Sandbox bool
+
+	// Profiler, when set, measures every statement and FRG_Fn call
+	Profiler *Profiler
+
+	// Coverage, when set, counts the statements and If branches run
+	Coverage *Coverage
+
+	// modules already evaluated, by path, each file runs once per program
+	modules map[string]*Module
+	// the FRG_Use being evaluated, the parser already refuses cycles
+	// but an AST built by hand could still have one
+	loading []*UseStatement
+}
+
+// Option configures the runtime of a new environment
+type Option func(*Runtime)
+
+// WithMaxCallDepth limits the number of nested FRG_Fn calls, 0 removes the limit
+// (the go stack can then overflow and crash the process)
+func WithMaxCallDepth(depth int) Option {
+	return func(rt *Runtime) {
+		rt.MaxCallDepth = depth
+	}
+}
+
+// WithMaxSteps stops the program after n executed statements
+func WithMaxSteps(n int) Option {
+	return func(rt *Runtime) {
+		rt.MaxSteps = n
+	}
+}
+
+// WithMaxArrayElements limits the array elements a program allocates to n,
+// counted over the whole run so many small arrays cannot get around it
+func WithMaxArrayElements(n int) Option {
+	return func(rt *Runtime) {
+		rt.MaxArrayElements = n
+	}
+}
+
+// WithContext stops the program when ctx is done (context.WithTimeout for a time limit)
+func WithContext(ctx context.Context) Option {
+	return func(rt *Runtime) {
+		rt.Context = ctx
+	}
+}
+
+// WithSandbox forbids reading files and the standard input (FRG_Use and FRG_Input),
+// the parser must be told too, see Parser.DisableIncludes
+func WithSandbox() Option {
+	return func(rt *Runtime) {
+		rt.Sandbox = true
+	}
+}
+
+// Frame is one FRG_Fn call in progress
+type Frame struct {
+	Function *Function
+	Call     *CallExpression // where it was called from
+	Env      *Environment
+}
+
+func NewRuntime(opts ...Option) *Runtime {
+	rt := &Runtime{
+		In:           bufio.NewReader(os.Stdin),
+		Out:          os.Stdout,
+		MaxCallDepth: DefaultMaxCallDepth,
+		Context:      context.Background(),
+	}
+	for _, opt := range opts {
+		opt(rt)
+	}
+	return rt
+}
+
+func (rt *Runtime) pushFrame(frame *Frame) {
+	rt.Frames = append(rt.Frames, frame)
+	if rt.Profiler != nil {
+		rt.Profiler.enter(frame.Function)
+	}
+}
+
+func (rt *Runtime) popFrame() {
+	if rt.Profiler != nil {
+		rt.Profiler.exit(rt.Frames[len(rt.Frames)-1].Function)
+	}
+	rt.Frames = rt.Frames[:len(rt.Frames)-1]
+}
+
+// beforeStatement is called by Eval before every statement
+func (rt *Runtime) beforeStatement(stmt Statement, env *Environment) *Error {
+	if err := rt.step(startToken(stmt)); err != nil {
+		return err
+	}
+	if rt.Profiler != nil {
+		rt.Profiler.statement(stmt, rt.Frames)
+	}
+	if rt.Coverage != nil {
+		rt.Coverage.statement(stmt)
+	}
+	if rt.Hook != nil {
+		rt.Hook(stmt, env)
+	}
+	return nil
+}
+
+// step counts one executed statement
+func (rt *Runtime) step(tok Token) *Error {
+	rt.steps++
+	if rt.MaxSteps > 0 && rt.steps > rt.MaxSteps {
+		return newLimitError(ErrorStepLimit, tok, "step limit exceeded, more than %d statements executed", rt.MaxSteps)
+	}
+	return nil
+}
+
+// checkContext reports a canceled or timed out run
+func (rt *Runtime) checkContext(tok Token) *Error {
+	switch rt.Context.Err() {
+	case nil:
+		return nil
+	case context.DeadlineExceeded:
+		return newLimitError(ErrorTimeout, tok, "time limit exceeded")
+	default:
+		return newLimitError(ErrorCanceled, tok, "execution canceled")
+	}
+}
+
+// allocate counts n new array elements, it reports going over MaxArrayElements
+// (the elements are never given back, a frog program has no way to free them)
+func (rt *Runtime) allocate(tok Token, n int64) *Error {
+	if rt.MaxArrayElements > 0 && rt.elements+n > int64(rt.MaxArrayElements) {
+		return newLimitError(ErrorMemoryLimit, tok, "%d more array elements exceed the limit of %d elements, %d are already allocated",
+			n, rt.MaxArrayElements, rt.elements)
+	}
+	rt.elements += n
+	return nil
+}
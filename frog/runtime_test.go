@@ -0,0 +1,118 @@
+// Copyright (C) by abdenour souane
+// you have a right to modify it upgrade it or do whatever you want
+// but u have to keep my name on it
+
+package frog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+const foreverSource = `FRG_Begin
+    FRG_Int i#
+    i := 0#
+    Repeat
+        i := i + 1#
+    Until [False]
+FRG_End
+`
+
+// many small arrays, 3 elements each time
+const arraysSource = `FRG_Begin
+    FRG_Int i#
+    i := 0#
+    Repeat
+        FRG_Int[] a#
+        a := {1, 2, 3}#
+        i := i + 1#
+    Until [i == 10]
+    FRG_Print "done"#
+FRG_End
+`
+
+// runLimited runs code with opts, the error that stopped it is returned
+func runLimited(t *testing.T, code string, opts ...Option) (string, *Error) {
+	t.Helper()
+	parser := NewParser(NewFileLexer("limits.frg", code))
+	program := parser.ParseProgram()
+	if parser.IsThereAnyErrors() {
+		t.Fatalf("does not parse: %s", parser.Diagnostics()[0].Message)
+	}
+	var out bytes.Buffer
+	env := NewEnvironment(opts...)
+	env.Runtime().Out = &out
+	env.Runtime().In = bufio.NewReader(strings.NewReader("12\n"))
+	err, _ := Eval(program, env).(*Error)
+	return out.String(), err
+}
+
+func checkLimit(t *testing.T, err *Error, kind ErrorKind, message string) {
+	t.Helper()
+	if err == nil {
+		t.Fatalf("the program finished, want a %s error", kind)
+	}
+	if err.Kind != kind || !strings.Contains(err.Message, message) {
+		t.Errorf("got %s %q, want %s with %q", err.Kind, err.Message, kind, message)
+	}
+}
+
+func TestMaxSteps(t *testing.T) {
+	_, err := runLimited(t, foreverSource, WithMaxSteps(100))
+	checkLimit(t, err, ErrorStepLimit, "more than 100 statements")
+
+	if out, err := runLimited(t, arraysSource, WithMaxSteps(1000)); err != nil || out != "done" {
+		t.Errorf("got %q %v, want done", out, err)
+	}
+}
+
+func TestContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := runLimited(t, foreverSource, WithContext(ctx))
+	checkLimit(t, err, ErrorTimeout, "time limit exceeded")
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = runLimited(t, foreverSource, WithContext(ctx))
+	checkLimit(t, err, ErrorCanceled, "execution canceled")
+}
+
+// the limit is on every element allocated by the run, not on one array
+func TestMaxArrayElements(t *testing.T) {
+	_, err := runLimited(t, arraysSource, WithMaxArrayElements(20))
+	checkLimit(t, err, ErrorMemoryLimit, "3 more array elements exceed the limit of 20 elements, 18 are already allocated")
+
+	if out, err := runLimited(t, arraysSource, WithMaxArrayElements(30)); err != nil || out != "done" {
+		t.Errorf("30 elements: got %q %v, want done", out, err)
+	}
+
+	grow := "FRG_Begin\n    FRG_Int[] a#\n    a[9] := 1#\n    a[19] := 1#\nFRG_End\n"
+	_, err = runLimited(t, grow, WithMaxArrayElements(15))
+	checkLimit(t, err, ErrorMemoryLimit, "10 more array elements")
+
+	sized := "FRG_Begin\n    FRG_Print map([5], FRG_Fn(FRG_Int x) : FRG_Int := x)#\nFRG_End\n"
+	_, err = runLimited(t, sized, WithMaxArrayElements(9))
+	checkLimit(t, err, ErrorMemoryLimit, "5 more array elements")
+}
+
+func TestSandbox(t *testing.T) {
+	_, err := runLimited(t, "FRG_Begin\n    FRG_Int x#\n    FRG_Input x#\nFRG_End\n", WithSandbox())
+	checkLimit(t, err, ErrorSandbox, "FRG_Input is not allowed")
+
+	if out, err := runLimited(t, "FRG_Begin\n    FRG_Int x#\n    FRG_Input x#\n    FRG_Print x#\nFRG_End\n"); err != nil || out != "12" {
+		t.Errorf("without the sandbox: got %q %v, want 12", out, err)
+	}
+
+	// the parser refuses FRG_Use before anything runs
+	parser := NewParser(NewFileLexer("limits.frg", "FRG_Begin\n    FRG_Use \"std\"#\nFRG_End\n"))
+	parser.DisableIncludes()
+	parser.ParseProgram()
+	if d := parser.Diagnostics(); len(d) != 1 || d[0].Code != ErrorSandbox.String() {
+		t.Errorf("got %v, want one sandbox diagnostic", d)
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright (C) by abdenour souane
+// you have a right to modify it upgrade it or do whatever you want
+// but u have to keep my name on it
+
+package frog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// frog test runs the functions named test_xxx (without parameters) found at the top
+// level of a file, each one in a fresh environment: the rest of the file runs first
+// (it declares the functions and globals the tests use) and then the test is called,
+// a test fails when it stops with an error, usually a false Assert
+
+// TestPrefix starts the name of every test function
+const TestPrefix = "test_"
+
+type TestResult struct {
+	File     string
+	Name     string
+	Line     int // of the FRG_Fn
+	Passed   bool
+	Error    *Error // why it failed
+	Output   string // what it printed
+	Duration time.Duration
+}
+
+// TestFunctions returns the test functions of program matching filter (nil for all)
+func TestFunctions(program *Program, filter *regexp.Regexp) []*FunctionDeclarationStatement {
+	tests := []*FunctionDeclarationStatement{}
+	for _, stmt := range program.Statements {
+		fn, ok := stmt.(*FunctionDeclarationStatement)
+		if !ok || fn.Name == nil || !strings.HasPrefix(fn.Name.Value, TestPrefix) || len(fn.Parameters) != 0 {
+			continue
+		}
+		if filter != nil && !filter.MatchString(fn.Name.Value) {
+			continue
+		}
+		tests = append(tests, fn)
+	}
+	return tests
+}
+
+// RunTest runs one test function of program in a new environment
+func RunTest(file string, program *Program, test *FunctionDeclarationStatement, opts ...Option) TestResult {
+	result := TestResult{File: file, Name: test.Name.Value, Line: test.Token.Line}
+	var out bytes.Buffer
+	env := NewEnvironment(opts...)
+	env.Runtime().Out = &out
+	env.Runtime().In = bufio.NewReader(strings.NewReader("")) // tests don't read the terminal
+
+	start := time.Now()
+	evaluated := Eval(program, env)
+	if !isError(evaluated) {
+		// called like a statement, a FRG_Void test gives no value and passes
+		call := &CallExpression{Token: test.Name.Token, Function: &Identifier{Token: test.Name.Token, Value: test.Name.Value}}
+		evaluated = evalCall(call, env)
+		if err, ok := evaluated.(*Error); ok && len(err.Stack) > 0 {
+			// the runner made that call, the file has no line for it
+			err.Stack = err.Stack[:len(err.Stack)-1]
+		}
+	}
+	result.Duration = time.Since(start)
+	result.Output = out.String()
+
+	if err, ok := evaluated.(*Error); ok {
+		result.Error = err
+		return result
+	}
+	result.Passed = true
+	return result
+}
+
+// Position is where the test failed, file:line:col
+func (r TestResult) Position() string {
+	if r.Error == nil {
+		return fmt.Sprintf("%s:%d", r.File, r.Line)
+	}
+	file := r.Error.File
+	if file == "" {
+		file = r.File
+	}
+	return fmt.Sprintf("%s:%d:%d", file, r.Error.Line, r.Error.Col)
+}
+
+// WriteTAP writes the results in the Test Anything Protocol (version 13)
+func WriteTAP(w io.Writer, results []TestResult) {
+	fmt.Fprintln(w, "TAP version 13")
+	fmt.Fprintf(w, "1..%d\n", len(results))
+	for i, r := range results {
+		if r.Passed {
+			fmt.Fprintf(w, "ok %d - %s: %s\n", i+1, r.File, r.Name)
+			continue
+		}
+		fmt.Fprintf(w, "not ok %d - %s: %s\n", i+1, r.File, r.Name)
+		fmt.Fprintln(w, "  ---")
+		fmt.Fprintf(w, "  message: %q\n", r.Error.Message)
+		fmt.Fprintf(w, "  kind: %s\n", r.Error.Kind)
+		fmt.Fprintf(w, "  at: %q\n", r.Position())
+		if r.Output != "" {
+			fmt.Fprintln(w, "  output: |")
+			for _, line := range strings.Split(strings.TrimRight(r.Output, "\n"), "\n") {
+				fmt.Fprintf(w, "    %s\n", line)
+			}
+		}
+		fmt.Fprintln(w, "  ...")
+	}
+}
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the results as JUnit XML, one testsuite per file
+func WriteJUnit(w io.Writer, results []TestResult) error {
+	suites := junitSuites{}
+	index := make(map[string]int)
+	for _, r := range results {
+		i, ok := index[r.File]
+		if !ok {
+			i = len(suites.Suites)
+			index[r.File] = i
+			suites.Suites = append(suites.Suites, junitSuite{Name: r.File})
+		}
+		suite := &suites.Suites[i]
+		c := junitCase{Name: r.Name, Classname: r.File, Time: seconds(r.Duration), SystemOut: r.Output}
+		if !r.Passed {
+			suite.Failures++
+			c.Failure = &junitFailure{
+				Message: r.Error.Message,
+				Type:    r.Error.Kind.String(),
+				Text:    r.Position() + ": " + r.Error.Message + "\n" + r.Error.Traceback(),
+			}
+		}
+		suite.Tests++
+		suite.Cases = append(suite.Cases, c)
+	}
+	for i := range suites.Suites {
+		var total time.Duration
+		for _, r := range results {
+			if r.File == suites.Suites[i].Name {
+				total += r.Duration
+			}
+		}
+		suites.Suites[i].Time = seconds(total)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,138 @@
+// Copyright (C) by abdenour souane
+// you have a right to modify it upgrade it or do whatever you want
+// but u have to keep my name on it
+
+package frog
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// every test of tests/math_test.frg passes, the FRG_Void ones included
+func TestRunTest(t *testing.T) {
+	file := filepath.Join(goldenDir, "math_test.frg")
+	code, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	program := parseSource(t, file, string(code))
+	tests := TestFunctions(program, nil)
+	if len(tests) != 4 {
+		t.Fatalf("found %d tests, want 4", len(tests))
+	}
+	for _, test := range tests {
+		if r := RunTest(file, program, test); !r.Passed {
+			t.Errorf("%s failed: %s", r.Name, r.Error.Inspect())
+		}
+	}
+}
+
+const failingTests = `FRG_Begin
+    FRG_Fn check(FRG_Int x)
+    Begin
+        Assert [x > 1] "too small"#
+    End
+
+    FRG_Fn test_small()
+    Begin
+        FRG_Print "checking\n"#
+        check(1)#
+    End
+
+    FRG_Fn test_big()
+    Begin
+        check(2)#
+    End
+
+    FRG_Fn test_with_parameter(FRG_Int x)
+    Begin
+    End
+FRG_End
+`
+
+func runTests(t *testing.T, filter *regexp.Regexp) []TestResult {
+	t.Helper()
+	program := parseSource(t, "t_test.frg", failingTests)
+	results := []TestResult{}
+	for _, test := range TestFunctions(program, filter) {
+		r := RunTest("t_test.frg", program, test)
+		r.Duration = 0 // the reports below are compared as text
+		results = append(results, r)
+	}
+	return results
+}
+
+func TestRunTestFailure(t *testing.T) {
+	results := runTests(t, nil)
+	if len(results) != 2 {
+		t.Fatalf("got %d tests, want test_small and test_big", len(results))
+	}
+	small := results[0]
+	if small.Name != "test_small" || small.Passed || small.Error.Kind != ErrorAssertion {
+		t.Fatalf("got %+v, want a failed assertion in test_small", small)
+	}
+	if small.Position() != "t_test.frg:4:9" || small.Output != "checking\n" {
+		t.Errorf("failed at %s with output %q", small.Position(), small.Output)
+	}
+	// the call made by the runner is not in the traceback
+	want := "    at check (t_test.frg:4:9) called from t_test.frg:10:9\n"
+	if got := small.Error.Traceback(); got != want {
+		t.Errorf("traceback\n%s\nwant\n%s", got, want)
+	}
+	if !results[1].Passed || results[1].Position() != "t_test.frg:13" {
+		t.Errorf("test_big: got %+v", results[1])
+	}
+}
+
+func TestRunTestFilter(t *testing.T) {
+	results := runTests(t, regexp.MustCompile("big$"))
+	if len(results) != 1 || results[0].Name != "test_big" {
+		t.Errorf("got %+v, want test_big only", results)
+	}
+}
+
+func TestWriteTAP(t *testing.T) {
+	var out bytes.Buffer
+	WriteTAP(&out, runTests(t, nil))
+	want := `TAP version 13
+1..2
+not ok 1 - t_test.frg: test_small
+  ---
+  message: "assertion failed: too small"
+  kind: assertion-failed
+  at: "t_test.frg:4:9"
+  output: |
+    checking
+  ...
+ok 2 - t_test.frg: test_big
+`
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteJUnit(&out, runTests(t, nil)); err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="t_test.frg" tests="2" failures="1" time="0.000">
+    <testcase name="test_small" classname="t_test.frg" time="0.000">
+      <failure message="assertion failed: too small" type="assertion-failed">t_test.frg:4:9: assertion failed: too small&#xA;    at check (t_test.frg:4:9) called from t_test.frg:10:9&#xA;</failure>
+      <system-out>checking&#xA;</system-out>
+    </testcase>
+    <testcase name="test_big" classname="t_test.frg" time="0.000"></testcase>
+  </testsuite>
+</testsuites>
+`
+	if got := strings.TrimSpace(out.String()) + "\n"; got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
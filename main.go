@@ -1,12 +1,19 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	fp "path/filepath"
+	"regexp"
 	// for signals
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 	// frog code
 	"frog_programming_language/frog"
 )
@@ -20,14 +27,98 @@ func main() {
 		os.Exit(0)
 	}()
 
+	// sub commands : frog lint ...
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "lint":
+			os.Exit(runLint(os.Args[2:]))
+		case "debug":
+			os.Exit(runDebug(os.Args[2:]))
+		case "cover":
+			os.Exit(runCover(os.Args[2:]))
+		case "test":
+			os.Exit(runTest(os.Args[2:]))
+		case "get":
+			os.Exit(runGet(os.Args[2:]))
+		case "vendor":
+			os.Exit(runVendor(os.Args[2:]))
+		case "lsp":
+			// the editor talks to us on stdin/stdout
+			if err := frog.ServeLSP(os.Stdin, os.Stdout); err != nil {
+				fmt.Fprintln(os.Stderr, "lsp:", err)
+				os.Exit(1)
+			}
+			return
+		case "dap":
+			// the debugger client talks to us on stdin/stdout
+			if err := frog.ServeDAP(os.Stdin, os.Stdout); err != nil {
+				fmt.Fprintln(os.Stderr, "dap:", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	os.Exit(runFile())
+}
+
+// exit status of `frog <file>`, so a grader can tell what stopped the program:
+// 1 for an error of the program, 3 for a parse error and one code per limit of the runtime
+const exitParseError = 3
+
+var exitCodes = map[frog.ErrorKind]int{
+	frog.ErrorRuntime:       1,
+	frog.ErrorAssertion:     1,
+	frog.ErrorStackOverflow: 4,
+	frog.ErrorStepLimit:     5,
+	frog.ErrorTimeout:       6,
+	frog.ErrorCanceled:      7,
+	frog.ErrorMemoryLimit:   8,
+	frog.ErrorSandbox:       9,
+}
+
+// diagnosticsExitCode is exitParseError, or the code of the limit a diagnostic is about
+// (FRG_Use refused by the sandbox)
+func diagnosticsExitCode(diagnostics []frog.Diagnostic) int {
+	for _, d := range diagnostics {
+		if d.Code == frog.ErrorSandbox.String() {
+			return exitCodes[frog.ErrorSandbox]
+		}
+	}
+	return exitParseError
+}
+
+// runFile implements `frog [options] <filepath>`, it returns the process exit code
+func runFile() int {
 	var parse *bool = flag.Bool("parse", false, "set to true to parse the file")
 	var lex *bool = flag.Bool("lex", false, "set to true to lex the file")
+	var jsonOutput *bool = flag.Bool("json", false, "print errors as JSON diagnostics (for tools)")
+	var maxDepth *int = flag.Int("max-depth", frog.DefaultMaxCallDepth, "maximum number of nested FRG_Fn calls, 0 for no limit")
+	var maxSteps *int = flag.Int("max-steps", 0, "stop after this many executed statements, 0 for no limit")
+	var maxArray *int = flag.Int("max-array", 0, "maximum number of array elements allocated by the program, 0 for no limit")
+	var timeout *time.Duration = flag.Duration("timeout", 0, "stop the program after this time (e.g. 2s), 0 for no limit")
+	var sandbox *bool = flag.Bool("sandbox", false, "forbid FRG_Use and FRG_Input (for untrusted programs)")
+	var profile *string = flag.String("profile", "", "write a pprof profile of the frog functions and lines to this file and print the hot spots")
+	var profileTop *int = flag.Int("profile-top", 10, "number of lines and functions shown by the -profile report")
+	var cover *string = flag.String("cover", "", "count the statements and branches run and add them to this coverage profile")
+	var coverHTML *string = flag.String("cover-html", "", "write an HTML coverage report of the run to this file")
+	var includes searchPath
+	flag.Var(&includes, "I", "directory where FRG_Use looks for files, before $"+frog.SearchPathEnv+" (repeatable)")
 	flag.Parse()
 
 	if flag.NArg() == 0 {
 		fmt.Println("Usage: frog [options] <filepath>")
+		fmt.Println("       frog lint [options] <filepath>...")
+		fmt.Println("       frog debug [-I dir] <filepath>")
+		fmt.Println("       frog cover [-html file] <profile>...")
+		fmt.Println("       frog test [options] [file or directory]...")
+		fmt.Println("       frog get [-u] [<package> <source> [version]]")
+		fmt.Println("       frog vendor")
+		fmt.Println("       frog lsp")
+		fmt.Println("       frog dap")
 		flag.PrintDefaults()
-		return
+		fmt.Println("exit status: 0 ok, 1 runtime error, 2 bad usage, 3 parse error, 4 stack overflow,")
+		fmt.Println("             5 step limit, 6 timeout, 7 canceled, 8 array limit, 9 sandbox")
+		return 2
 	}
 
 	filepath := flag.Arg(0)
@@ -35,11 +126,15 @@ func main() {
 	code, err := os.ReadFile(filepath)
 	if err != nil {
 		fmt.Println("Error reading file:", err)
-		return
+		return 2
 	}
 
-	lexer := frog.NewLexer(string(code))
+	lexer := frog.NewFileLexer(filepath, string(code))
 	parser := frog.NewParser(lexer)
+	parser.AddSearchPath(includes...)
+	if *sandbox {
+		parser.DisableIncludes()
+	}
 	program := parser.ParseProgram()
 
 	if *lex {
@@ -50,11 +145,8 @@ func main() {
 	} else if *parse {
 		fmt.Println("Parsing the file...")
 		if parser.IsThereAnyErrors() {
-			fmt.Println("Parser has errors:")
-			for _, msg := range parser.Errors() {
-				fmt.Println("\t" + msg)
-			}
-			return
+			printDiagnostics(parser.Diagnostics(), *jsonOutput)
+			return diagnosticsExitCode(parser.Diagnostics())
 		}
 
 		fmt.Println("Generated AST:")
@@ -62,17 +154,497 @@ func main() {
 
 	} else {
 		if parser.IsThereAnyErrors() {
-			fmt.Println("Parser has errors:")
-			for _, msg := range parser.Errors() {
-				fmt.Println("\t" + msg)
-			}
-			return
+			printDiagnostics(parser.Diagnostics(), *jsonOutput)
+			return diagnosticsExitCode(parser.Diagnostics())
 		}
 
-		env := frog.NewEnvironment()
+		options := []frog.Option{
+			frog.WithMaxCallDepth(*maxDepth),
+			frog.WithMaxSteps(*maxSteps),
+			frog.WithMaxArrayElements(*maxArray),
+		}
+		if *timeout > 0 {
+			ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+			defer cancel()
+			options = append(options, frog.WithContext(ctx))
+		}
+		if *sandbox {
+			options = append(options, frog.WithSandbox())
+		}
+
+		var profiler *frog.Profiler
+		if *profile != "" {
+			profiler = frog.NewProfiler()
+			options = append(options, frog.WithProfiler(profiler))
+		}
+
+		var coverage *frog.Coverage
+		if *cover != "" || *coverHTML != "" {
+			coverage = frog.NewCoverage()
+			coverage.Add(program)
+			options = append(options, frog.WithCoverage(coverage))
+		}
+
+		env := frog.NewEnvironment(options...)
 		evaluated := frog.Eval(program, env)
-		if evaluated != nil {
+		if profiler != nil {
+			profiler.Stop()
+			writeProfile(profiler, *profile, *profileTop)
+		}
+		if coverage != nil {
+			writeCoverage(coverage, *cover, *coverHTML)
+		}
+		if rerr, ok := evaluated.(*frog.Error); ok && *jsonOutput {
+			file := rerr.File
+			if file == "" {
+				file = filepath
+			}
+			printDiagnostics([]frog.Diagnostic{{
+				Severity:  frog.SeverityError,
+				File:      file,
+				Line:      rerr.Line,
+				Column:    rerr.Col,
+				EndLine:   rerr.Line,
+				EndColumn: rerr.Col + 1,
+				Code:      rerr.Kind.String(),
+				Message:   rerr.Message,
+			}}, true)
+		} else if evaluated != nil {
 			fmt.Println(evaluated.Inspect())
+			if rerr, ok := evaluated.(*frog.Error); ok {
+				fmt.Print(rerr.Traceback())
+			}
+		}
+		if rerr, ok := evaluated.(*frog.Error); ok {
+			if code, ok := exitCodes[rerr.Kind]; ok {
+				return code
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// writeProfile writes the pprof file and the report on stderr, out of the program output
+func writeProfile(profiler *frog.Profiler, path string, top int) {
+	file, err := os.Create(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error writing profile:", err)
+		return
+	}
+	defer file.Close()
+	if err := profiler.WritePprof(file); err != nil {
+		fmt.Fprintln(os.Stderr, "Error writing profile:", err)
+		return
+	}
+	profiler.WriteReport(os.Stderr, top)
+	fmt.Fprintf(os.Stderr, "\nprofile written to %s (go tool pprof %s)\n", path, path)
+}
+
+// writeCoverage adds the run to the profile (so the runs of a test suite add up)
+// and writes the reports, the summary goes to stderr
+func writeCoverage(coverage *frog.Coverage, profile, htmlFile string) {
+	if profile != "" {
+		if file, err := os.Open(profile); err == nil {
+			err = coverage.ReadProfile(file)
+			file.Close()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading coverage profile %s: %v\n", profile, err)
+				return
+			}
+		}
+		if err := writeFile(profile, coverage.WriteProfile); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing coverage profile:", err)
+			return
+		}
+	}
+	if htmlFile != "" {
+		if err := writeFile(htmlFile, coverage.WriteHTML); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing coverage report:", err)
+			return
+		}
+	}
+	coverage.WriteSummary(os.Stderr)
+}
+
+func writeFile(path string, write func(w io.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// runCover implements `frog cover`, it reports coverage profiles written by -cover
+func runCover(args []string) int {
+	flags := flag.NewFlagSet("cover", flag.ExitOnError)
+	var htmlFile *string = flags.String("html", "", "write an HTML report to this file")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		fmt.Println("Usage: frog cover [-html file] <profile>...")
+		flags.PrintDefaults()
+		return 2
+	}
+
+	coverage := frog.NewCoverage()
+	for _, profile := range flags.Args() {
+		file, err := os.Open(profile)
+		if err != nil {
+			fmt.Println("Error reading file:", err)
+			return 1
+		}
+		err = coverage.ReadProfile(file)
+		file.Close()
+		if err != nil {
+			fmt.Printf("%s: %v\n", profile, err)
+			return 1
+		}
+	}
+	if *htmlFile != "" {
+		if err := writeFile(*htmlFile, coverage.WriteHTML); err != nil {
+			fmt.Println("Error writing coverage report:", err)
+			return 1
+		}
+	}
+	coverage.WriteSummary(os.Stdout)
+	return 0
+}
+
+// runTest implements `frog test`, it runs the test_xxx functions of *_test.frg files
+func runTest(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	var run *string = flags.String("run", "", "run only the tests whose name matches this regular expression")
+	var verbose *bool = flags.Bool("v", false, "print every test, not only the failed ones")
+	var format *string = flags.String("format", "text", "output format: text, tap or junit")
+	var includes searchPath
+	flags.Var(&includes, "I", "directory where FRG_Use looks for files, before $"+frog.SearchPathEnv+" (repeatable)")
+	flags.Parse(args)
+
+	var filter *regexp.Regexp
+	if *run != "" {
+		var err error
+		if filter, err = regexp.Compile(*run); err != nil {
+			fmt.Println("bad -run pattern:", err)
+			return 2
+		}
+	}
+	if *format != "text" && *format != "tap" && *format != "junit" {
+		fmt.Printf("unknown format %q, use text, tap or junit\n", *format)
+		return 2
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files := []string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Println("Error reading file:", err)
+			return 1
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		matches, _ := fp.Glob(fp.Join(path, "*_test.frg"))
+		files = append(files, matches...)
+	}
+
+	status := 0
+	var results []frog.TestResult
+	for _, file := range files {
+		code, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Println("Error reading file:", err)
+			status = 1
+			continue
+		}
+		parser := frog.NewParser(frog.NewFileLexer(file, string(code)))
+		parser.AddSearchPath(includes...)
+		program := parser.ParseProgram()
+		if parser.IsThereAnyErrors() {
+			// keep the tap/junit output clean on stdout
+			writeDiagnostics(os.Stderr, parser.Diagnostics(), false)
+			if *format == "text" {
+				fmt.Printf("FAIL\t%s [parse errors]\n", file)
+			}
+			status = 1
+			continue
+		}
+
+		failed, tests := 0, frog.TestFunctions(program, filter)
+		for _, test := range tests {
+			if *format == "text" && *verbose {
+				fmt.Printf("=== RUN   %s\n", test.Name.Value)
+			}
+			result := frog.RunTest(file, program, test)
+			results = append(results, result)
+			if !result.Passed {
+				failed++
+			}
+			if *format == "text" {
+				printTestResult(result, *verbose)
+			}
+		}
+		if failed > 0 {
+			status = 1
+		}
+		if *format == "text" {
+			if failed > 0 {
+				fmt.Printf("FAIL\t%s (%d of %d tests failed)\n", file, failed, len(tests))
+			} else {
+				fmt.Printf("ok\t%s (%d tests)\n", file, len(tests))
+			}
+		}
+	}
+
+	switch *format {
+	case "tap":
+		frog.WriteTAP(os.Stdout, results)
+	case "junit":
+		if err := frog.WriteJUnit(os.Stdout, results); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	return status
+}
+
+func printTestResult(result frog.TestResult, verbose bool) {
+	if result.Passed {
+		if verbose {
+			fmt.Printf("--- PASS: %s (%.2fs)\n", result.Name, result.Duration.Seconds())
+		}
+		return
+	}
+	fmt.Printf("--- FAIL: %s (%.2fs)\n", result.Name, result.Duration.Seconds())
+	fmt.Printf("    %s: %s\n", result.Position(), result.Error.Message)
+	fmt.Print(result.Error.Traceback())
+	if result.Output != "" {
+		fmt.Println("    output:")
+		for _, line := range strings.Split(strings.TrimRight(result.Output, "\n"), "\n") {
+			fmt.Println("        " + line)
+		}
+	}
+}
+
+// runGet implements `frog get`: with a package it adds it to frog.mod (or changes it),
+// then every package of frog.mod is fetched and pinned in frog.lock
+func runGet(args []string) int {
+	flags := flag.NewFlagSet("get", flag.ExitOnError)
+	var update *bool = flags.Bool("u", false, "fetch the latest version of the packages instead of the locked one")
+	flags.Parse(args)
+	if n := flags.NArg(); n != 0 && n != 2 && n != 3 {
+		fmt.Println("Usage: frog get [-u] [<package> <source> [version]]")
+		flags.PrintDefaults()
+		return 2
+	}
+
+	project, err := frog.FindProject(".")
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if project == nil {
+		if flags.NArg() == 0 {
+			fmt.Printf("no %s found, frog get <package> <source> creates it\n", frog.ManifestFile)
+			return 1
+		}
+		dir, _ := os.Getwd()
+		project = &frog.Project{Dir: dir, Manifest: &frog.Manifest{}, Lock: &frog.Lock{}}
+	}
+
+	if flags.NArg() > 0 {
+		r := frog.Requirement{Name: flags.Arg(0), Source: flags.Arg(1), Version: flags.Arg(2)}
+		if err := r.Check(); err != nil {
+			fmt.Println(err)
+			return 2
+		}
+		if existing := project.Manifest.Require(r.Name); existing != nil {
+			*existing = r
+		} else {
+			project.Manifest.Requires = append(project.Manifest.Requires, r)
+		}
+		if err := project.Manifest.Write(fp.Join(project.Dir, frog.ManifestFile)); err != nil {
+			fmt.Println(err)
+			return 1
+		}
+	}
+	if err := project.Get(*update, os.Stdout); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
+}
+
+// runVendor implements `frog vendor`, it copies the locked packages in vendor/
+func runVendor(args []string) int {
+	if len(args) != 0 {
+		fmt.Println("Usage: frog vendor")
+		return 2
+	}
+	project, err := frog.FindProject(".")
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if project == nil {
+		fmt.Printf("no %s found\n", frog.ManifestFile)
+		return 1
+	}
+	if err := project.Vendor(os.Stdout); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
+}
+
+// runDebug implements `frog debug`, it runs the file under the step debugger
+func runDebug(args []string) int {
+	flags := flag.NewFlagSet("debug", flag.ExitOnError)
+	var includes searchPath
+	flags.Var(&includes, "I", "directory where FRG_Use looks for files, before $"+frog.SearchPathEnv+" (repeatable)")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Println("Usage: frog debug [-I dir] <filepath>")
+		flags.PrintDefaults()
+		return 2
+	}
+	filepath := flags.Arg(0)
+	code, err := os.ReadFile(filepath)
+	if err != nil {
+		fmt.Println("Error reading file:", err)
+		return 1
+	}
+
+	parser := frog.NewParser(frog.NewFileLexer(filepath, string(code)))
+	parser.AddSearchPath(includes...)
+	program := parser.ParseProgram()
+	if parser.IsThereAnyErrors() {
+		printDiagnostics(parser.Diagnostics(), false)
+		return 1
+	}
+
+	debugger := frog.NewDebugger(filepath, string(code), os.Stdin, os.Stdout)
+	evaluated := debugger.Run(program)
+	if rerr, ok := evaluated.(*frog.Error); ok {
+		fmt.Println(rerr.Inspect())
+		fmt.Print(rerr.Traceback())
+		return 1
+	}
+	if !debugger.Quit() {
+		fmt.Println("program finished")
+	}
+	return 0
+}
+
+// runLint implements `frog lint`, it returns the process exit code
+func runLint(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	var disable *string = flags.String("disable", "", "comma separated list of rules to turn off")
+	var list *bool = flags.Bool("rules", false, "list the available rules and exit")
+	var jsonOutput *bool = flags.Bool("json", false, "print the issues as JSON diagnostics (for tools)")
+	var includes searchPath
+	flags.Var(&includes, "I", "directory where FRG_Use looks for files, before $"+frog.SearchPathEnv+" (repeatable)")
+	flags.Parse(args)
+
+	if *list {
+		for _, rule := range frog.LintRules() {
+			fmt.Printf("%-22s %s\n", rule.ID, rule.Description)
+		}
+		return 0
+	}
+	if flags.NArg() == 0 {
+		fmt.Println("Usage: frog lint [options] <filepath>...")
+		flags.PrintDefaults()
+		return 2
+	}
+
+	linter := frog.NewLinter()
+	if *disable != "" {
+		for _, id := range strings.Split(*disable, ",") {
+			if err := linter.Disable(strings.TrimSpace(id)); err != nil {
+				fmt.Println(err)
+				return 2
+			}
+		}
+	}
+
+	status := 0
+	var all []frog.Diagnostic
+	for _, filepath := range flags.Args() {
+		code, err := os.ReadFile(filepath)
+		if err != nil {
+			fmt.Println("Error reading file:", err)
+			status = 1
+			continue
+		}
+
+		lexer := frog.NewFileLexer(filepath, string(code))
+		parser := frog.NewParser(lexer)
+		parser.AddSearchPath(includes...)
+		program := parser.ParseProgram()
+		if parser.IsThereAnyErrors() {
+			all = append(all, parser.Diagnostics()...)
+			status = 1
+			continue
+		}
+
+		issues := linter.Lint(program, lexer.Comments())
+		if len(issues) > 0 {
+			all = append(all, issues...)
+			status = 1
+		}
+	}
+	printDiagnostics(all, *jsonOutput)
+	return status
+}
+
+// searchPath collects the -I flags
+type searchPath []string
+
+func (s *searchPath) String() string {
+	return strings.Join(*s, string(os.PathListSeparator))
+}
+
+func (s *searchPath) Set(dir string) error {
+	*s = append(*s, dir)
+	return nil
+}
+
+// printDiagnostics prints every diagnostic with its source line,
+// or all of them as a JSON array for tools
+func printDiagnostics(diagnostics []frog.Diagnostic, asJSON bool) {
+	writeDiagnostics(os.Stdout, diagnostics, asJSON)
+}
+
+// writeDiagnostics is printDiagnostics on w
+func writeDiagnostics(w io.Writer, diagnostics []frog.Diagnostic, asJSON bool) {
+	if asJSON {
+		if diagnostics == nil {
+			diagnostics = []frog.Diagnostic{}
+		}
+		out, _ := json.MarshalIndent(diagnostics, "", "  ")
+		fmt.Fprintln(w, string(out))
+		return
+	}
+	sources := make(map[string]string)
+	for _, d := range diagnostics {
+		source, ok := sources[d.File]
+		if !ok {
+			// diagnostics of FRG_Use files point into those files
+			if code, err := frog.ReadSource(d.File); err == nil {
+				source = string(code)
+			}
+			sources[d.File] = source
 		}
+		fmt.Fprint(w, d.Render(source))
 	}
 }
@@ -0,0 +1,25 @@
+-- start the frog language server (`frog lsp`) for every frog buffer
+-- needs the frog binary in $PATH
+if vim.fn.executable("frog") == 1 then
+  vim.lsp.start({
+    name = "frog",
+    cmd = { "frog", "lsp" },
+    root_dir = vim.fs.dirname(vim.fs.find({ "main.fr", ".git" }, { upward = true })[1]),
+  })
+end
+
+-- register the frog debug adapter (`frog dap`) when nvim-dap is installed
+local ok, dap = pcall(require, "dap")
+if ok and vim.fn.executable("frog") == 1 then
+  dap.adapters.frog = { type = "executable", command = "frog", args = { "dap" } }
+  dap.configurations.frog = dap.configurations.frog or {
+    {
+      type = "frog",
+      request = "launch",
+      name = "Debug the current file",
+      program = "${file}",
+      cwd = "${workspaceFolder}",
+      stopOnEntry = false,
+    },
+  }
+end
@@ -2,8 +2,8 @@ if exists("b:current_syntax")
   finish
 endif
 
-syn keyword frogKeyword FRG_Begin FRG_End If Else Begin End Repeat Until Break Continue FRG_Input FRG_Fn FRG_Use
-syn keyword frogType FRG_Int FRG_Real FRG_Strg
+syn keyword frogKeyword FRG_Begin FRG_End If Else Begin End Repeat Until Break Continue Assert FRG_Input FRG_Fn FRG_Use FRG_Export As From FRG_Static FRG_Const FRG_Enum
+syn keyword frogType FRG_Int FRG_Real FRG_Strg FRG_Void
 syn keyword frogStatement FRG_Print
 syn keyword frogBoolean True False
 
@@ -0,0 +1,12 @@
+// Copyright (C) by abdenour souane
+// you have a right to modify it upgrade it or do whatever you want
+// but u have to keep my name on it
+
+// Package std ships the frog standard library inside the binary,
+// `FRG_Use "std"#` works from any directory without the sources
+package std
+
+import "embed"
+
+//go:embed *.frg
+var Files embed.FS
@@ -16,6 +16,13 @@ if [ ! -x "$FROG_INTERPRETER" ]; then
     exit 1
 fi
 
+# FROG_COVER=cover.out ./run_tests.sh adds the coverage of every test to cover.out
+# (see it with: frog cover -html cover.html cover.out)
+COVER_ARGS=""
+if [ -n "$FROG_COVER" ]; then
+    COVER_ARGS="-cover $FROG_COVER"
+fi
+
 # Counters
 FAILED_TESTS=0
 PASSED_TESTS=0
@@ -30,7 +37,7 @@ for test_file in *.frg; do
             echo -e "${BLUE}Running test:${NC} $test_file"
             
             # Run the interpreter and capture stdout
-            output=$(./$FROG_INTERPRETER "$test_file" 2> /dev/null)
+            output=$(./$FROG_INTERPRETER $COVER_ARGS "$test_file" 2> /dev/null)
             
             # Read the expected output
             expected_output=$(cat "$expected_file")